@@ -0,0 +1,70 @@
+package tractor
+
+import (
+	"fmt"
+	"os"
+)
+
+// adapterRef delivers messages to the actor that created it, they are converted when the actor processes them.
+type adapterRef struct {
+	context *localActorContext
+	adapt   func(msg interface{}) interface{}
+}
+
+// adaptedMessage is queued in the mailbox of the actor instead of the original message.
+type adaptedMessage struct {
+	ref *adapterRef
+	msg interface{}
+}
+
+func (ref *adapterRef) Tell(ctx ActorContext, msg interface{}) {
+	ref.context.tellFrom(ctx, envelope{sender: ctx.Self(), msg: &adaptedMessage{ref: ref, msg: msg}})
+}
+
+// MessageAdapter doesn't spawn an actor, adapt runs on the actor when it processes the message.
+func (ctx *localActorContext) MessageAdapter(adapt func(msg interface{}) interface{}) ActorRef {
+	return &adapterRef{context: ctx, adapt: adapt}
+}
+
+// adaptMessage converts the adapted message, Sender() returns its sender meanwhile. A panic of the adapter fails the
+// actor like a panic of the handler.
+func (ctx *localActorContext) adaptMessage(e envelope, adapted *adaptedMessage) (msg interface{}) {
+	ctx.current = e
+	ctx.currentEnvelope = &ctx.current
+	defer func() {
+		ctx.currentEnvelope = nil
+		ctx.current = envelope{}
+		if err := recover(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "actor message adapter panic: %s\n", err)
+			ctx.failed(err)
+			ctx.stop()
+			msg = nil
+		}
+	}()
+	return adapted.ref.adapt(adapted.msg)
+}
+
+// deadLetter publishes the envelope as a dead letter, adapted messages are published as they were told.
+func (ctx *localActorContext) deadLetter(e envelope) {
+	letter := DeadLetter{Message: e.msg, Sender: e.sender, Recipient: ctx.self}
+	if adapted, ok := e.msg.(*adaptedMessage); ok {
+		letter.Message, letter.Recipient = adapted.msg, adapted.ref
+	}
+	ctx.system.eventStream.publish(letter)
+}
+
+type testKitAdapter struct {
+	ctx   *testKitContext
+	adapt func(msg interface{}) interface{}
+}
+
+// Tell converts the message right away and puts it into the SelfInbox.
+func (ref testKitAdapter) Tell(_ ActorContext, msg interface{}) {
+	if msg = ref.adapt(msg); msg != nil {
+		ref.ctx.self.Tell(nil, msg)
+	}
+}
+
+func (ctx *testKitContext) MessageAdapter(adapt func(msg interface{}) interface{}) ActorRef {
+	return testKitAdapter{ctx: ctx, adapt: adapt}
+}
@@ -0,0 +1,122 @@
+package tractor
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type adapterRequest struct {
+	n       int
+	replyTo ActorRef
+}
+
+type adaptedReply struct {
+	n      int
+	sender ActorRef
+}
+
+// doubling replies to requests with the doubled number.
+func doubling(ctx ActorContext) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		request := msg.(adapterRequest)
+		request.replyTo.Tell(ctx, request.n*2)
+		return nil
+	}
+}
+
+var _ = Describe("MessageAdapter", func() {
+	var system ActorSystem
+	var probe *TestProbe
+
+	BeforeEach(func() {
+		system = Start(echo)
+		probe = NewTestProbe(GinkgoT(), system)
+	})
+
+	AfterEach(func() {
+		Expect(system.Terminate(context.Background())).To(Succeed())
+	})
+
+	It("converts messages on the actor", func() {
+		children := make(chan ActorRef, 1)
+		system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			child := ctx.Spawn(doubling)
+			children <- child
+			adapter := ctx.MessageAdapter(func(msg interface{}) interface{} {
+				if n := msg.(int); n != 0 {
+					return adaptedReply{n: n, sender: ctx.Sender()}
+				}
+				return nil
+			})
+			child.Tell(ctx, adapterRequest{n: 0, replyTo: adapter})
+			child.Tell(ctx, adapterRequest{n: 21, replyTo: adapter})
+			return func(msg interface{}) MessageHandler {
+				probe.Tell(ctx, msg)
+				probe.Tell(ctx, ctx.Sender())
+				probe.Tell(ctx, len(ctx.Children()))
+				return nil
+			}
+		})
+		child := <-children
+		probe.ExpectMessage(adaptedReply{n: 42, sender: child}, time.Second)
+		probe.ExpectMessage(child, time.Second)
+		probe.ExpectMessage(1, time.Second)
+		probe.ExpectNoMessage(10 * time.Millisecond)
+	})
+
+	It("fails the actor when the adapter panics", func() {
+		adapters := make(chan ActorRef, 1)
+		ref := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			adapters <- ctx.MessageAdapter(func(msg interface{}) interface{} {
+				panic("bad reply")
+			})
+			return func(msg interface{}) MessageHandler {
+				return nil
+			}
+		})
+		probe.Watch(ref)
+		(<-adapters).Tell(system.Context(), 1)
+		terminated := ExpectMessageType[Terminated](probe, time.Second)
+		Expect(terminated.Cause.Reason).To(Equal(StopFailed))
+		Expect(terminated.Cause.Panic).To(Equal("bad reply"))
+	})
+
+	It("publishes dead letters with the original message", func() {
+		adapters := make(chan ActorRef, 1)
+		ref := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			adapters <- ctx.MessageAdapter(func(msg interface{}) interface{} {
+				return msg
+			})
+			return func(msg interface{}) MessageHandler {
+				return Stopped()
+			}
+		})
+		adapter := <-adapters
+		probe.Watch(ref)
+		ref.Tell(system.Context(), "stop")
+		probe.ExpectTerminated(ref, time.Second)
+
+		events, ch := ToChannel(system.Context(), 10)
+		system.Context().EventStream().Subscribe(events, DeadLetter{})
+		probe.Send(adapter, 1)
+		Eventually(ch).Should(Receive(Equal(DeadLetter{Message: 1, Sender: probe, Recipient: adapter})))
+	})
+
+	It("puts converted messages into the test kit inbox", func() {
+		kit := NewBehaviorTestKit(func(ctx ActorContext) MessageHandler {
+			adapter := ctx.MessageAdapter(func(msg interface{}) interface{} {
+				return adaptedReply{n: msg.(int)}
+			})
+			return func(msg interface{}) MessageHandler {
+				adapter.Tell(ctx, msg)
+				return nil
+			}
+		})
+		kit.Run(1)
+		Expect(kit.SelfInbox().Messages()).To(Equal([]interface{}{adaptedReply{n: 1}}))
+		Expect(kit.Effects()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,79 @@
+package tractor
+
+import "fmt"
+
+// BatchMessage is a message of a batch together with its sender.
+type BatchMessage struct {
+	Sender  ActorRef
+	Message interface{}
+}
+
+// Batch is delivered instead of individual messages to actors spawned with Batching. Messages are in the order they
+// were received. Stashing a BatchMessage stashes its message with its sender.
+type Batch []BatchMessage
+
+// BatchHandler handles a batch of messages.
+type BatchHandler func(batch Batch) MessageHandler
+
+// Batching delivers up to max messages available in the mailbox as a single Batch. Signals are delivered
+// individually. Sender() returns the sender of the last message of the batch. It panics if max isn't positive.
+func Batching(max int) SpawnOption {
+	if max < 1 {
+		panic(fmt.Sprintf("batch size must be positive: %d", max))
+	}
+	return func(settings *spawnSettings) {
+		settings.batchSize = max
+	}
+}
+
+// HandleBatches adapts the batch handler to a message handler. Other messages are unhandled.
+func HandleBatches(handler BatchHandler) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		if batch, ok := msg.(Batch); ok {
+			return handler(batch)
+		}
+		return Unhandled()
+	}
+}
+
+// processBatch delivers the envelope together with the following ones. Draining stops at the first command or
+// PoisonPill, which is processed after the batch. Drained messages are published as dead letters if the actor
+// stops before the batch is delivered.
+func (ctx *localActorContext) processBatch(first envelope) {
+	batch := Batch{{Sender: first.sender, Message: first.msg}}
+	var next interface{}
+	for len(batch) < ctx.batchSize {
+		item, ok := ctx.mailbox.take()
+		if !ok {
+			break
+		}
+		env, isEnvelope := item.(envelope)
+		if _, poisoned := env.msg.(PoisonPill); !isEnvelope || poisoned {
+			next = item
+			break
+		}
+		env, isEnvelope = ctx.unwrap(env)
+		if ctx.stopping {
+			break
+		}
+		if !isEnvelope {
+			continue
+		}
+		batch = append(batch, BatchMessage{Sender: env.sender, Message: env.msg})
+	}
+
+	if ctx.stopping {
+		for _, m := range batch {
+			ctx.deadLetter(envelope{sender: m.Sender, msg: m.Message})
+		}
+	} else {
+		ctx.current = envelope{sender: batch[len(batch)-1].Sender, msg: batch}
+		ctx.currentEnvelope = &ctx.current
+		ctx.become(ctx.deliver(ctx.handler, batch))
+		ctx.currentEnvelope = nil
+		ctx.current = envelope{}
+	}
+	if next != nil {
+		ctx.process(next)
+	}
+}
@@ -0,0 +1,151 @@
+package tractor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batching", func() {
+	var system ActorSystem
+	var probe *TestProbe
+	var gate chan struct{}
+
+	BeforeEach(func() {
+		system = Start(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				return Stopped()
+			}
+		})
+		probe = NewTestProbe(GinkgoT(), system)
+		gate = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		system.Root().Tell(system.Context(), "stop")
+	})
+
+	// send tells messages alternating between the probe and the system as senders.
+	send := func(ref ActorRef, messages ...interface{}) Batch {
+		var expected Batch
+		for i, msg := range messages {
+			if i%2 == 0 {
+				probe.Send(ref, msg)
+				expected = append(expected, BatchMessage{Sender: probe, Message: msg})
+			} else {
+				ref.Tell(system.Context(), msg)
+				expected = append(expected, BatchMessage{Sender: system.Context().Self(), Message: msg})
+			}
+		}
+		return expected
+	}
+
+	It("delivers available messages in order", func() {
+		batcher := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			<-gate
+			return HandleBatches(func(batch Batch) MessageHandler {
+				probe.Tell(ctx, batch)
+				return nil
+			})
+		}, Batching(10))
+		var messages []interface{}
+		for i := 0; i < 25; i++ {
+			messages = append(messages, i)
+		}
+		expected := send(batcher, messages...)
+		close(gate)
+
+		probe.ExpectMessage(expected[:10], time.Second)
+		probe.ExpectMessage(expected[10:20], time.Second)
+		probe.ExpectMessage(expected[20:], time.Second)
+		probe.ExpectNoMessage(10 * time.Millisecond)
+	})
+
+	It("delivers single message batches", func() {
+		batcher := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			<-gate
+			return HandleBatches(func(batch Batch) MessageHandler {
+				probe.Tell(ctx, batch)
+				return nil
+			})
+		}, Batching(1))
+		expected := send(batcher, 1, 2)
+		close(gate)
+
+		probe.ExpectMessage(expected[:1], time.Second)
+		probe.ExpectMessage(expected[1:], time.Second)
+	})
+
+	It("rejects non positive batch sizes", func() {
+		Expect(func() { Batching(0) }).To(Panic())
+	})
+
+	It("doesn't handle other messages", func() {
+		handler := HandleBatches(func(batch Batch) MessageHandler {
+			return nil
+		})
+		Expect(isUnhandled(handler("message"))).To(BeTrue())
+		Expect(handler(Batch{})).To(BeNil())
+	})
+
+	It("stashes messages with their senders", func() {
+		batcher := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			<-gate
+			stash := ctx.NewStash(10)
+			flushed := false
+			return HandleBatches(func(batch Batch) MessageHandler {
+				var handled Batch
+				for _, m := range batch {
+					switch {
+					case m.Message == "flush":
+						flushed = true
+						stash.UnstashAll(nil)
+					case !flushed && m.Message.(int)%2 == 1:
+						stash.Stash(m)
+					default:
+						handled = append(handled, m)
+					}
+				}
+				probe.Tell(ctx, handled)
+				return nil
+			})
+		}, Batching(10))
+		expected := send(batcher, 1, 2, 3, 4, "flush")
+		close(gate)
+
+		probe.ExpectMessage(Batch{expected[1], expected[3]}, time.Second)
+		probe.ExpectMessage(Batch{expected[0], expected[2]}, time.Second)
+	})
+
+	It("publishes drained messages as dead letters when the actor stops", func() {
+		adapters := make(chan ActorRef, 1)
+		batcher := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			adapters <- ctx.MessageAdapter(func(msg interface{}) interface{} {
+				panic("adapter failed")
+			})
+			<-gate
+			return HandleBatches(func(batch Batch) MessageHandler {
+				probe.Tell(ctx, batch)
+				return nil
+			})
+		}, Batching(10))
+		adapter := <-adapters
+		events, ch := ToChannel(system.Context(), 10)
+		system.Context().EventStream().Subscribe(events, DeadLetter{})
+		probe.Send(batcher, 1)
+		probe.Send(batcher, 2)
+		probe.Send(adapter, 3)
+		probe.Send(batcher, 4)
+		close(gate)
+
+		var messages []interface{}
+		for i := 0; i < 3; i++ {
+			var letter DeadLetter
+			Eventually(ch).Should(Receive(&letter))
+			messages = append(messages, letter.Message)
+		}
+		Expect(messages).To(ConsistOf(1, 2, 4))
+		probe.ExpectNoMessage(50 * time.Millisecond)
+	})
+})
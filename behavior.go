@@ -1,9 +1,20 @@
 package tractor
 
-import "reflect"
+import "unsafe"
 
 var stopped stoppedBehavior
 
+// the sentinels are allocated once, so that returning them doesn't allocate
+var (
+	stoppedHandler   MessageHandler = stopped.handle
+	unhandledHandler MessageHandler = unhandled.handle
+)
+
+// funcPointer returns the pointer to the function value. Copies of a function value share it.
+func funcPointer(handler MessageHandler) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&handler))
+}
+
 type stoppedBehavior struct {
 }
 
@@ -12,10 +23,35 @@ func (s *stoppedBehavior) handle(_ interface{}) MessageHandler {
 }
 
 func isStopped(handler MessageHandler) bool {
-	// todo: can this be made faster?
-	return reflect.ValueOf(handler).Pointer() == reflect.ValueOf(Stopped()).Pointer()
+	return funcPointer(handler) == funcPointer(stoppedHandler)
+}
+
+var unhandled unhandledBehavior
+
+type unhandledBehavior struct {
+}
+
+func (s *unhandledBehavior) handle(_ interface{}) MessageHandler {
+	panic("should not be called")
+}
+
+func isUnhandled(handler MessageHandler) bool {
+	return funcPointer(handler) == funcPointer(unhandledHandler)
+}
+
+// isSpecial reports whether the handler is a sentinel that shouldn't be used for the next message.
+func isSpecial(handler MessageHandler) bool {
+	return handler == nil || isStopped(handler) || isUnhandled(handler)
 }
 
 func ignoreAll(interface{}) MessageHandler {
 	return nil
 }
+
+func isSignal(msg interface{}) bool {
+	switch msg.(type) {
+	case PostInitSignal, PreRestartSignal, PostRestartSignal, PreStopSignal, PostStopSignal, ChildFailed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package tractor
+
+import (
+	"testing"
+	"time"
+)
+
+func benchmarkDispatchers(b *testing.B, run func(b *testing.B, options ...SystemOption)) {
+	b.Run("dedicated", func(b *testing.B) {
+		run(b)
+	})
+	b.Run("pool", func(b *testing.B) {
+		run(b, WithDispatcher(NewPoolDispatcher(0, 0)))
+	})
+}
+
+func reportThroughput(b *testing.B, start time.Time, messages int) {
+	b.ReportMetric(float64(messages)/time.Since(start).Seconds(), "msgs/s")
+}
+
+// BenchmarkPingPong measures latency of two actors exchanging b.N messages.
+func BenchmarkPingPong(b *testing.B) {
+	benchmarkDispatchers(b, func(b *testing.B, options ...SystemOption) {
+		b.ReportAllocs()
+		start := time.Now()
+		system := Start(func(ctx ActorContext) MessageHandler {
+			pong := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					ctx.Sender().Tell(ctx, msg)
+					return nil
+				}
+			})
+			pong.Tell(ctx, 0)
+			return func(msg interface{}) MessageHandler {
+				n := msg.(int) + 1
+				if n >= b.N {
+					return Stopped()
+				}
+				pong.Tell(ctx, n)
+				return nil
+			}
+		}, options...)
+		system.Wait()
+		reportThroughput(b, start, 2*b.N)
+	})
+}
+
+// BenchmarkFanIn measures many actors sending b.N messages in total to a single actor.
+func BenchmarkFanIn(b *testing.B) {
+	const senders = 100
+	benchmarkDispatchers(b, func(b *testing.B, options ...SystemOption) {
+		b.ReportAllocs()
+		start := time.Now()
+		system := Start(func(ctx ActorContext) MessageHandler {
+			for i := 0; i < senders; i++ {
+				count := b.N / senders
+				if i < b.N%senders {
+					count++
+				}
+				ctx.Spawn(func(ctx ActorContext) MessageHandler {
+					for j := 0; j < count; j++ {
+						ctx.Parent().Tell(ctx, j)
+					}
+					return Stopped()
+				})
+			}
+			received := 0
+			return func(msg interface{}) MessageHandler {
+				received++
+				if received == b.N {
+					return Stopped()
+				}
+				return nil
+			}
+		}, options...)
+		system.Wait()
+		reportThroughput(b, start, b.N)
+	})
+}
+
+// BenchmarkFanOut measures a single actor sending b.N messages in total to many actors.
+func BenchmarkFanOut(b *testing.B) {
+	const receivers = 100
+	benchmarkDispatchers(b, func(b *testing.B, options ...SystemOption) {
+		b.ReportAllocs()
+		start := time.Now()
+		system := Start(func(ctx ActorContext) MessageHandler {
+			refs := make([]ActorRef, receivers)
+			for i := range refs {
+				refs[i] = ctx.Spawn(func(ctx ActorContext) MessageHandler {
+					return func(msg interface{}) MessageHandler {
+						if msg == nil {
+							return Stopped()
+						}
+						return nil
+					}
+				})
+			}
+			for i := 0; i < b.N; i++ {
+				refs[i%receivers].Tell(ctx, i)
+			}
+			for _, ref := range refs {
+				ref.Tell(ctx, nil)
+			}
+			return Stopped()
+		}, options...)
+		system.Wait()
+		reportThroughput(b, start, b.N)
+	})
+}
+
+// BenchmarkSpawnStorm measures spawning and stopping b.N actors.
+func BenchmarkSpawnStorm(b *testing.B) {
+	benchmarkDispatchers(b, func(b *testing.B, options ...SystemOption) {
+		b.ReportAllocs()
+		start := time.Now()
+		system := Start(func(ctx ActorContext) MessageHandler {
+			for i := 0; i < b.N; i++ {
+				ctx.Spawn(func(ctx ActorContext) MessageHandler {
+					return Stopped()
+				})
+			}
+			return Stopped()
+		}, options...)
+		system.Wait()
+		reportThroughput(b, start, b.N)
+	})
+}
@@ -0,0 +1,85 @@
+package tractor
+
+import "sync"
+
+// FromChannel pumps values from the channel into the target mailbox on behalf of the ctx actor. The pump runs on
+// its own goroutine and blocks while the target mailbox is full. It stops when the channel is closed or either
+// actor terminates.
+// The returned channel is closed when the pump stops.
+func FromChannel(ctx ActorContext, ch <-chan interface{}, target ActorRef) <-chan struct{} {
+	finished := make(chan struct{})
+	owner := doneOf(ctx.Self())
+	targetDone := doneOf(target)
+	sender := ctx.Self()
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if local, ok := target.(*localActorRef); ok {
+					if !local.context.tell(envelope{sender: sender, msg: msg}, owner) {
+						return
+					}
+				} else {
+					target.Tell(ctx, msg)
+				}
+			case <-targetDone:
+				return
+			case <-owner:
+				return
+			}
+		}
+	}()
+	return finished
+}
+
+// doneOf returns a channel that is closed when the actor terminates or nil if it can't be known.
+func doneOf(ref ActorRef) <-chan struct{} {
+	if local, ok := ref.(*localActorRef); ok && local != nil {
+		return local.context.done
+	}
+	return nil
+}
+
+type channelRef struct {
+	mu     sync.RWMutex
+	ch     chan interface{}
+	done   <-chan struct{}
+	closed bool
+}
+
+func (ref *channelRef) Tell(_ ActorContext, msg interface{}) {
+	ref.mu.RLock()
+	defer ref.mu.RUnlock()
+	if ref.closed {
+		return
+	}
+	select {
+	case ref.ch <- msg:
+	case <-ref.done:
+	}
+}
+
+func (ref *channelRef) close() {
+	ref.mu.Lock()
+	defer ref.mu.Unlock()
+	ref.closed = true
+	close(ref.ch)
+}
+
+// ToChannel creates a reference whose received messages appear on the returned channel. Tell blocks
+// while the channel is full. The channel is closed when the ctx actor terminates.
+func ToChannel(ctx ActorContext, size int) (ActorRef, <-chan interface{}) {
+	ref := &channelRef{ch: make(chan interface{}, size), done: doneOf(ctx.Self())}
+	if ref.done != nil {
+		go func() {
+			<-ref.done
+			ref.close()
+		}()
+	}
+	return ref, ref.ch
+}
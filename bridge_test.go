@@ -0,0 +1,75 @@
+package tractor
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Channel bridges", func() {
+	Context("FromChannel", func() {
+		It("pumps values until the channel is closed", func() {
+			ch := make(chan interface{})
+			var received []interface{}
+			system := Start(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					if msg == "stop" {
+						return Stopped()
+					}
+					received = append(received, msg)
+					return nil
+				}
+			})
+
+			finished := FromChannel(system.Context(), ch, system.Root())
+			for i := 0; i < 2000; i++ {
+				ch <- i
+			}
+			close(ch)
+			<-finished
+			system.Root().Tell(system.Context(), "stop")
+			system.Wait()
+			Expect(received).To(HaveLen(2000))
+		})
+
+		It("stops when the target stops", func() {
+			ch := make(chan interface{})
+			system := Start(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					return Stopped()
+				}
+			})
+
+			finished := FromChannel(system.Context(), ch, system.Root())
+			ch <- "stop"
+			system.Wait()
+			Eventually(finished).Should(BeClosed())
+		})
+	})
+
+	Context("ToChannel", func() {
+		It("delivers messages to the channel and closes it when the owner stops", func() {
+			var ch <-chan interface{}
+			ready := make(chan struct{})
+			system := Start(func(ctx ActorContext) MessageHandler {
+				var ref ActorRef
+				ref, ch = ToChannel(ctx, 0)
+				close(ready)
+				return func(msg interface{}) MessageHandler {
+					ref.Tell(ctx, msg)
+					if msg == "stop" {
+						return Stopped()
+					}
+					return nil
+				}
+			})
+
+			<-ready
+			system.Root().Tell(system.Context(), "a")
+			system.Root().Tell(system.Context(), "stop")
+			Expect(<-ch).To(Equal("a"))
+			Expect(<-ch).To(Equal("stop"))
+			Eventually(ch).Should(BeClosed())
+			system.Wait()
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package tractor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cancellable is a scheduled action that can be cancelled. Stop returns false if it already ran or was stopped.
+type Cancellable interface {
+	Stop() bool
+}
+
+// Clock is used by the system for all scheduled messages: timers, state timeouts, supervisor backoff and the
+// timeouts of the built-in actors.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Cancellable
+}
+
+type realClock struct{}
+
+func RealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Cancellable {
+	return time.AfterFunc(d, f)
+}
+
+// ManualClock is a clock that only moves when advanced. Scheduled functions run on the goroutine calling Advance.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	at    time.Time
+	seq   int
+	f     func()
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Cancellable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &manualTimer{clock: c, at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].at.Equal(c.timers[j].at) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	return t
+}
+
+// Advance moves the clock forward running all functions that are due in the order of their time, including
+// the ones scheduled by them.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].at.After(target) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// PendingTimers returns the number of functions that haven't run yet.
+func (c *ManualClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package tractor
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ManualClock", func() {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("runs functions in the order of their time when advanced", func() {
+		clock := NewManualClock(start)
+		var order []int
+		clock.AfterFunc(2*time.Second, func() { order = append(order, 2) })
+		clock.AfterFunc(time.Second, func() {
+			order = append(order, 1)
+			clock.AfterFunc(500*time.Millisecond, func() { order = append(order, 15) })
+		})
+		stopped := clock.AfterFunc(time.Second, func() { order = append(order, 0) })
+		clock.AfterFunc(3*time.Second, func() { order = append(order, 3) })
+		Expect(stopped.Stop()).To(BeTrue())
+		Expect(stopped.Stop()).To(BeFalse())
+
+		clock.Advance(2 * time.Second)
+		Expect(order).To(Equal([]int{1, 15, 2}))
+		Expect(clock.Now()).To(Equal(start.Add(2 * time.Second)))
+		Expect(clock.PendingTimers()).To(Equal(1))
+	})
+
+	It("drives actor timers", func() {
+		clock := NewManualClock(start)
+		system := Start(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler { return Stopped() }
+		}, WithClock(clock))
+		probe := NewTestProbe(GinkgoT(), system)
+		actor := system.Context().Spawn(WithTimers(func(timers TimerScheduler) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				timers.StartPeriodicTimer("tick", "tick", time.Second)
+				return func(msg interface{}) MessageHandler {
+					if msg == "stop" {
+						return Stopped()
+					}
+					probe.Tell(ctx, msg)
+					return nil
+				}
+			}
+		}))
+
+		Eventually(clock.PendingTimers).Should(Equal(1))
+		clock.Advance(500 * time.Millisecond)
+		probe.ExpectNoMessage(20 * time.Millisecond)
+		clock.Advance(500 * time.Millisecond)
+		probe.ExpectMessage("tick", time.Second)
+		Eventually(clock.PendingTimers).Should(Equal(1))
+		clock.Advance(time.Second)
+		probe.ExpectMessage("tick", time.Second)
+
+		probe.Watch(actor)
+		probe.Send(actor, "stop")
+		probe.ExpectTerminated(actor, time.Second)
+		Expect(clock.PendingTimers()).To(Equal(0))
+		probe.Send(system.Root(), "stop")
+		system.Wait()
+	})
+
+	It("drives supervisor backoff", func() {
+		clock := NewManualClock(start)
+		d := NewDeterministicDispatcher(0)
+		setups := 0
+		var received []interface{}
+		strategy := SupervisorStrategy{MinBackoff: time.Second, MaxBackoff: time.Minute}
+		system := Start(Supervise(func(ctx ActorContext) MessageHandler {
+			setups++
+			return func(msg interface{}) MessageHandler {
+				if msg == "fail" {
+					panic(errors.New("failed"))
+				}
+				received = append(received, msg)
+				return nil
+			}
+		}, strategy), WithClock(clock), WithDispatcher(d))
+
+		system.Root().Tell(system.Context(), "fail")
+		system.Root().Tell(system.Context(), "a")
+		d.RunUntilIdle()
+		Expect(setups).To(Equal(1))
+		Expect(received).To(BeEmpty())
+
+		clock.Advance(999 * time.Millisecond)
+		d.RunUntilIdle()
+		Expect(setups).To(Equal(1))
+
+		clock.Advance(time.Millisecond)
+		d.RunUntilIdle()
+		Expect(setups).To(Equal(2))
+		Expect(received).To(Equal([]interface{}{"a"}))
+	})
+})
@@ -0,0 +1,107 @@
+package tractor
+
+import (
+	"log"
+	"reflect"
+)
+
+// Interceptor is invoked instead of the target handler and decides whether and how to call it.
+type Interceptor func(msg interface{}, target MessageHandler) MessageHandler
+
+// Intercept passes every message to the interceptor. The interceptor keeps wrapping the handlers returned by
+// the target.
+func Intercept(handler MessageHandler, interceptor Interceptor) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		next := interceptor(msg, handler)
+		if isSpecial(next) {
+			return next
+		}
+		handler = next
+		return nil
+	}
+}
+
+// OrElse tries the first handler and passes the message to the second one if the first returns Unhandled().
+func OrElse(first, second MessageHandler) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		next := first(msg)
+		if isUnhandled(next) {
+			next = second(msg)
+			if isSpecial(next) {
+				return next
+			}
+			second = next
+			return nil
+		}
+		if isSpecial(next) {
+			return next
+		}
+		first = next
+		return nil
+	}
+}
+
+func WithStash(capacity int, factory func(stash StashBuffer) SetupHandler) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		return factory(ctx.NewStash(capacity))(ctx)
+	}
+}
+
+// Logging logs every message and the outcome of handling it.
+func Logging(handler MessageHandler) MessageHandler {
+	return Intercept(handler, func(msg interface{}, target MessageHandler) MessageHandler {
+		log.Printf("actor received %T: %+v", msg, msg)
+		next := target(msg)
+		switch {
+		case next == nil:
+		case isStopped(next):
+			log.Printf("actor stopped after %T", msg)
+		case isUnhandled(next):
+			log.Printf("actor didn't handle %T", msg)
+		default:
+			log.Printf("actor changed handler after %T", msg)
+		}
+		return next
+	})
+}
+
+// ReceiveBuilder builds a handler that dispatches messages by their type.
+type ReceiveBuilder struct {
+	handlers map[reflect.Type]func(msg interface{}) MessageHandler
+	any      func(msg interface{}) MessageHandler
+}
+
+// NewReceiveBuilder starts a handler built with On and OnAny, like NewReceiveBuilder().On(T{}, fn).Build().
+func NewReceiveBuilder() *ReceiveBuilder {
+	return &ReceiveBuilder{handlers: map[reflect.Type]func(msg interface{}) MessageHandler{}}
+}
+
+// On handles messages of the same type as the sample.
+func (b *ReceiveBuilder) On(sample interface{}, handler func(msg interface{}) MessageHandler) *ReceiveBuilder {
+	b.handlers[reflect.TypeOf(sample)] = handler
+	return b
+}
+
+// OnAny handles messages that don't have a type specific handler.
+func (b *ReceiveBuilder) OnAny(handler func(msg interface{}) MessageHandler) *ReceiveBuilder {
+	b.any = handler
+	return b
+}
+
+// Build returns the handler. Messages without a handler are Unhandled().
+func (b *ReceiveBuilder) Build() MessageHandler {
+	handlers := make(map[reflect.Type]func(msg interface{}) MessageHandler, len(b.handlers))
+	for t, h := range b.handlers {
+		handlers[t] = h
+	}
+	fallback := b.any
+	return func(msg interface{}) MessageHandler {
+		if handler, ok := handlers[reflect.TypeOf(msg)]; ok {
+			return handler(msg)
+		}
+		if fallback != nil {
+			return fallback(msg)
+		}
+		return Unhandled()
+	}
+}
@@ -0,0 +1,147 @@
+package tractor
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recording returns a handler that records received messages and stops on "stop".
+func recording(received *[]interface{}) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		if msg == "stop" {
+			return Stopped()
+		}
+		*received = append(*received, msg)
+		return nil
+	}
+}
+
+func runActor(setup SetupHandler, msgs ...interface{}) {
+	system := Start(setup)
+	for _, msg := range msgs {
+		system.Root().Tell(system.Context(), msg)
+	}
+	system.Wait()
+}
+
+var _ = Describe("Combinators", func() {
+	It("keeps the handler for unhandled messages", func() {
+		var received []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(int); ok {
+					return Unhandled()
+				}
+				return recording(&received)(msg)
+			}
+		}, "a", 1, "b", "stop")
+		Expect(received).To(Equal([]interface{}{"a", "b"}))
+	})
+
+	It("intercepts messages", func() {
+		var received []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			return Intercept(recording(&received), func(msg interface{}, target MessageHandler) MessageHandler {
+				if s, ok := msg.(string); ok && s != "stop" {
+					return target(s + "!")
+				}
+				return target(msg)
+			})
+		}, "a", 1, "stop")
+		Expect(received).To(Equal([]interface{}{"a!", 1}))
+	})
+
+	It("keeps intercepting the new handler", func() {
+		var received []interface{}
+		counter := 0
+		var counting func(n int) MessageHandler
+		counting = func(n int) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				if msg == "stop" {
+					return Stopped()
+				}
+				received = append(received, n)
+				return counting(n + 1)
+			}
+		}
+		runActor(func(ctx ActorContext) MessageHandler {
+			return Intercept(counting(0), func(msg interface{}, target MessageHandler) MessageHandler {
+				counter++
+				return target(msg)
+			})
+		}, "a", "b", "c", "stop")
+		Expect(received).To(Equal([]interface{}{0, 1, 2}))
+		Expect(counter).To(Equal(4))
+	})
+
+	It("falls back to the second handler", func() {
+		var ints, others []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			first := NewReceiveBuilder().On(0, func(msg interface{}) MessageHandler {
+				ints = append(ints, msg)
+				return nil
+			}).Build()
+			return OrElse(first, recording(&others))
+		}, 1, "a", 2, "stop")
+		Expect(ints).To(Equal([]interface{}{1, 2}))
+		Expect(others).To(Equal([]interface{}{"a"}))
+	})
+
+	It("dispatches messages by type", func() {
+		var received []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			return NewReceiveBuilder().
+				On("", func(msg interface{}) MessageHandler {
+					if msg == "stop" {
+						return Stopped()
+					}
+					received = append(received, "string")
+					return nil
+				}).
+				On(0, func(msg interface{}) MessageHandler {
+					received = append(received, "int")
+					return nil
+				}).
+				OnAny(func(msg interface{}) MessageHandler {
+					received = append(received, "any")
+					return nil
+				}).
+				Build()
+		}, "a", 1, 1.5, "stop")
+		Expect(received).To(Equal([]interface{}{"string", "int", "any"}))
+	})
+
+	It("provides a stash", func() {
+		var received []interface{}
+		runActor(WithStash(10, func(stash StashBuffer) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					if msg == "open" {
+						return stash.UnstashAll(recording(&received))
+					}
+					stash.Stash(msg)
+					return nil
+				}
+			}
+		}), "a", "b", "open", "c", "stop")
+		Expect(received).To(Equal([]interface{}{"a", "b", "c"}))
+	})
+
+	It("logs messages", func() {
+		var buffer bytes.Buffer
+		log.SetOutput(&buffer)
+		defer log.SetOutput(os.Stderr)
+
+		var received []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			return Logging(recording(&received))
+		}, 42, "stop")
+		Expect(received).To(Equal([]interface{}{42}))
+		Expect(buffer.String()).To(ContainSubstring("actor received int: 42"))
+		Expect(buffer.String()).To(ContainSubstring("actor stopped after string"))
+	})
+})
@@ -0,0 +1,318 @@
+package tractor
+
+import "time"
+
+// ReplicatedData is a convergent data type. Merge has to be commutative, associative and idempotent.
+// Values are immutable: every modification returns a new value.
+type ReplicatedData interface {
+	Merge(other ReplicatedData) ReplicatedData
+}
+
+// GCounter is a grow-only counter.
+type GCounter struct {
+	state map[string]uint64
+}
+
+func NewGCounter() GCounter {
+	return GCounter{}
+}
+
+func (c GCounter) Increment(node string, delta uint64) GCounter {
+	state := make(map[string]uint64, len(c.state)+1)
+	for k, v := range c.state {
+		state[k] = v
+	}
+	state[node] += delta
+	return GCounter{state: state}
+}
+
+func (c GCounter) Value() uint64 {
+	var sum uint64
+	for _, v := range c.state {
+		sum += v
+	}
+	return sum
+}
+
+func (c GCounter) Merge(other ReplicatedData) ReplicatedData {
+	return c.merge(other.(GCounter))
+}
+
+func (c GCounter) merge(o GCounter) GCounter {
+	state := make(map[string]uint64, len(c.state))
+	for k, v := range c.state {
+		state[k] = v
+	}
+	for k, v := range o.state {
+		if v > state[k] {
+			state[k] = v
+		}
+	}
+	return GCounter{state: state}
+}
+
+// PNCounter is a counter supporting both increments and decrements.
+type PNCounter struct {
+	p GCounter
+	n GCounter
+}
+
+func NewPNCounter() PNCounter {
+	return PNCounter{}
+}
+
+func (c PNCounter) Increment(node string, delta uint64) PNCounter {
+	return PNCounter{p: c.p.Increment(node, delta), n: c.n}
+}
+
+func (c PNCounter) Decrement(node string, delta uint64) PNCounter {
+	return PNCounter{p: c.p, n: c.n.Increment(node, delta)}
+}
+
+func (c PNCounter) Value() int64 {
+	return int64(c.p.Value()) - int64(c.n.Value())
+}
+
+func (c PNCounter) Merge(other ReplicatedData) ReplicatedData {
+	o := other.(PNCounter)
+	return PNCounter{p: c.p.merge(o.p), n: c.n.merge(o.n)}
+}
+
+// GSet is a grow-only set. Elements have to be comparable.
+type GSet struct {
+	elements map[interface{}]struct{}
+}
+
+func NewGSet() GSet {
+	return GSet{}
+}
+
+func (s GSet) Add(element interface{}) GSet {
+	elements := make(map[interface{}]struct{}, len(s.elements)+1)
+	for e := range s.elements {
+		elements[e] = struct{}{}
+	}
+	elements[element] = struct{}{}
+	return GSet{elements: elements}
+}
+
+func (s GSet) Contains(element interface{}) bool {
+	_, ok := s.elements[element]
+	return ok
+}
+
+func (s GSet) Elements() []interface{} {
+	result := make([]interface{}, 0, len(s.elements))
+	for e := range s.elements {
+		result = append(result, e)
+	}
+	return result
+}
+
+func (s GSet) Merge(other ReplicatedData) ReplicatedData {
+	o := other.(GSet)
+	elements := make(map[interface{}]struct{}, len(s.elements)+len(o.elements))
+	for e := range s.elements {
+		elements[e] = struct{}{}
+	}
+	for e := range o.elements {
+		elements[e] = struct{}{}
+	}
+	return GSet{elements: elements}
+}
+
+// ORSet is an observed-remove set: concurrent add and remove of the same element results in the element being present.
+type ORSet struct {
+	// versions counts adds observed from every node
+	versions map[string]uint64
+	// elements maps an element to the dots (node -> version) of the adds that are still visible
+	elements map[interface{}]map[string]uint64
+}
+
+func NewORSet() ORSet {
+	return ORSet{}
+}
+
+func (s ORSet) copy() ORSet {
+	result := ORSet{
+		versions: make(map[string]uint64, len(s.versions)+1),
+		elements: make(map[interface{}]map[string]uint64, len(s.elements)+1),
+	}
+	for k, v := range s.versions {
+		result.versions[k] = v
+	}
+	for e, dots := range s.elements {
+		result.elements[e] = dots
+	}
+	return result
+}
+
+func (s ORSet) Add(node string, element interface{}) ORSet {
+	result := s.copy()
+	result.versions[node]++
+	result.elements[element] = map[string]uint64{node: result.versions[node]}
+	return result
+}
+
+func (s ORSet) Remove(element interface{}) ORSet {
+	result := s.copy()
+	delete(result.elements, element)
+	return result
+}
+
+func (s ORSet) Contains(element interface{}) bool {
+	_, ok := s.elements[element]
+	return ok
+}
+
+func (s ORSet) Elements() []interface{} {
+	result := make([]interface{}, 0, len(s.elements))
+	for e := range s.elements {
+		result = append(result, e)
+	}
+	return result
+}
+
+func (s ORSet) Merge(other ReplicatedData) ReplicatedData {
+	o := other.(ORSet)
+	result := ORSet{
+		versions: make(map[string]uint64, len(s.versions)),
+		elements: make(map[interface{}]map[string]uint64, len(s.elements)),
+	}
+	for k, v := range s.versions {
+		result.versions[k] = v
+	}
+	for k, v := range o.versions {
+		if v > result.versions[k] {
+			result.versions[k] = v
+		}
+	}
+
+	// a dot survives if both sides have it or the side that doesn't have it has never seen it
+	mergeDots := func(dots, otherDots map[string]uint64, otherVersions map[string]uint64) map[string]uint64 {
+		merged := map[string]uint64{}
+		for node, version := range dots {
+			if otherDots[node] == version || version > otherVersions[node] {
+				merged[node] = version
+			}
+		}
+		return merged
+	}
+	for e, dots := range s.elements {
+		merged := mergeDots(dots, o.elements[e], o.versions)
+		for node, version := range mergeDots(o.elements[e], dots, s.versions) {
+			if version > merged[node] {
+				merged[node] = version
+			}
+		}
+		if len(merged) > 0 {
+			result.elements[e] = merged
+		}
+	}
+	for e, dots := range o.elements {
+		if _, ok := s.elements[e]; ok {
+			continue
+		}
+		if merged := mergeDots(dots, nil, s.versions); len(merged) > 0 {
+			result.elements[e] = merged
+		}
+	}
+	return result
+}
+
+type lwwEntry struct {
+	value     interface{}
+	timestamp int64
+	node      string
+	removed   bool
+}
+
+func (e lwwEntry) newerThan(o lwwEntry) bool {
+	if e.timestamp != o.timestamp {
+		return e.timestamp > o.timestamp
+	}
+	return e.node > o.node
+}
+
+// LWWMap is a map where concurrent updates of the same key are resolved in favour of the latest one.
+type LWWMap struct {
+	entries map[string]lwwEntry
+}
+
+func NewLWWMap() LWWMap {
+	return LWWMap{}
+}
+
+func (m LWWMap) put(node string, key string, value interface{}, removed bool) LWWMap {
+	entries := make(map[string]lwwEntry, len(m.entries)+1)
+	for k, v := range m.entries {
+		entries[k] = v
+	}
+	timestamp := time.Now().UnixNano()
+	if existing, ok := entries[key]; ok && existing.timestamp >= timestamp {
+		timestamp = existing.timestamp + 1
+	}
+	entries[key] = lwwEntry{value: value, timestamp: timestamp, node: node, removed: removed}
+	return LWWMap{entries: entries}
+}
+
+func (m LWWMap) Put(node string, key string, value interface{}) LWWMap {
+	return m.put(node, key, value, false)
+}
+
+func (m LWWMap) Remove(node string, key string) LWWMap {
+	return m.put(node, key, nil, true)
+}
+
+func (m LWWMap) Get(key string) (interface{}, bool) {
+	e, ok := m.entries[key]
+	if !ok || e.removed {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (m LWWMap) Entries() map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, e := range m.entries {
+		if !e.removed {
+			result[k] = e.value
+		}
+	}
+	return result
+}
+
+func (m LWWMap) Merge(other ReplicatedData) ReplicatedData {
+	o := other.(LWWMap)
+	entries := make(map[string]lwwEntry, len(m.entries))
+	for k, v := range m.entries {
+		entries[k] = v
+	}
+	for k, v := range o.entries {
+		if existing, ok := entries[k]; !ok || v.newerThan(existing) {
+			entries[k] = v
+		}
+	}
+	return LWWMap{entries: entries}
+}
+
+// Flag is a boolean that can only be switched on.
+type Flag struct {
+	enabled bool
+}
+
+func NewFlag() Flag {
+	return Flag{}
+}
+
+func (f Flag) SwitchOn() Flag {
+	return Flag{enabled: true}
+}
+
+func (f Flag) Enabled() bool {
+	return f.enabled
+}
+
+func (f Flag) Merge(other ReplicatedData) ReplicatedData {
+	return Flag{enabled: f.enabled || other.(Flag).enabled}
+}
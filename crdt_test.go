@@ -0,0 +1,66 @@
+package tractor
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CRDT", func() {
+	It("GCounter merges increments from different nodes", func() {
+		a := NewGCounter().Increment("a", 2)
+		b := NewGCounter().Increment("b", 3)
+		merged := a.Merge(b).(GCounter)
+		Expect(merged.Value()).To(Equal(uint64(5)))
+		Expect(merged.Merge(a).(GCounter).Value()).To(Equal(uint64(5)))
+		Expect(b.Merge(a)).To(Equal(merged))
+	})
+
+	It("PNCounter counts both ways", func() {
+		a := NewPNCounter().Increment("a", 5)
+		b := NewPNCounter().Decrement("b", 7)
+		Expect(a.Merge(b).(PNCounter).Value()).To(Equal(int64(-2)))
+	})
+
+	It("GSet is a union", func() {
+		a := NewGSet().Add("x")
+		b := NewGSet().Add("y")
+		merged := a.Merge(b).(GSet)
+		Expect(merged.Contains("x")).To(BeTrue())
+		Expect(merged.Contains("y")).To(BeTrue())
+		Expect(merged.Elements()).To(HaveLen(2))
+	})
+
+	Context("ORSet", func() {
+		It("removes observed elements", func() {
+			a := NewORSet().Add("a", "x")
+			b := a.Merge(NewORSet()).(ORSet).Remove("x")
+			Expect(a.Merge(b).(ORSet).Contains("x")).To(BeFalse())
+			Expect(b.Merge(a).(ORSet).Contains("x")).To(BeFalse())
+		})
+
+		It("concurrent add wins", func() {
+			a := NewORSet().Add("a", "x")
+			b := a.Merge(NewORSet()).(ORSet).Remove("x")
+			a = a.Add("a", "x")
+			Expect(a.Merge(b).(ORSet).Contains("x")).To(BeTrue())
+			Expect(b.Merge(a).(ORSet).Contains("x")).To(BeTrue())
+		})
+	})
+
+	It("LWWMap keeps the latest value", func() {
+		a := NewLWWMap().Put("a", "k", 1)
+		b := a.Merge(NewLWWMap()).(LWWMap).Put("b", "k", 2)
+		v, ok := a.Merge(b).(LWWMap).Get("k")
+		Expect(ok).To(BeTrue())
+		Expect(v).To(Equal(2))
+
+		c := b.Remove("a", "k")
+		_, ok = b.Merge(c).(LWWMap).Get("k")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Flag stays on", func() {
+		Expect(NewFlag().Merge(NewFlag().SwitchOn()).(Flag).Enabled()).To(BeTrue())
+		Expect(NewFlag().Merge(NewFlag()).(Flag).Enabled()).To(BeFalse())
+	})
+})
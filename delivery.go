@@ -0,0 +1,322 @@
+package tractor
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultDeliveryWindowSize = 20
+const defaultResendInterval = time.Second
+
+type DeliverySettings struct {
+	// WindowSize limits the number of messages sent but not yet confirmed by the consumer.
+	WindowSize int
+	// ResendInterval is the interval after which unconfirmed messages are sent again.
+	ResendInterval time.Duration
+}
+
+func (s DeliverySettings) withDefaults() DeliverySettings {
+	if s.WindowSize == 0 {
+		s.WindowSize = defaultDeliveryWindowSize
+	}
+	if s.ResendInterval == 0 {
+		s.ResendInterval = defaultResendInterval
+	}
+	return s
+}
+
+// ProducerStart registers the producer with the producer controller.
+type ProducerStart struct {
+	Producer ActorRef
+}
+
+// RegisterConsumer connects the producer controller to the consumer controller.
+type RegisterConsumer struct {
+	ConsumerController ActorRef
+}
+
+// RequestNext is sent to the producer when the controller is ready to accept the next message.
+// The producer is expected to send exactly one message to SendNextTo.
+type RequestNext struct {
+	ProducerID   string
+	CurrentSeqNr uint64
+	SendNextTo   ActorRef
+}
+
+// ConsumerStart registers the consumer with the consumer controller.
+type ConsumerStart struct {
+	Consumer ActorRef
+}
+
+// Delivery is sent to the consumer. The consumer is expected to reply with Confirmed to ConfirmTo when
+// the message is processed, after which the next message is delivered.
+type Delivery struct {
+	ProducerID string
+	SeqNr      uint64
+	Message    interface{}
+	ConfirmTo  ActorRef
+}
+
+type Confirmed struct{}
+
+type DurableMessage struct {
+	SeqNr   uint64
+	Message interface{}
+}
+
+type DurableProducerState struct {
+	// CurrentSeqNr is the sequence number that will be assigned to the next message.
+	CurrentSeqNr          uint64
+	HighestConfirmedSeqNr uint64
+	Unconfirmed           []DurableMessage
+}
+
+// DurableProducerQueue stores the messages of a producer controller so that they can be resent
+// by a new controller after a crash.
+type DurableProducerQueue interface {
+	LoadState() DurableProducerState
+	StoreMessageSent(msg DurableMessage)
+	StoreMessageConfirmed(seqNr uint64)
+}
+
+type inMemoryDurableQueue struct {
+	mu    sync.Mutex
+	state DurableProducerState
+}
+
+// NewInMemoryDurableQueue creates a queue that survives the producer controller but not the process.
+func NewInMemoryDurableQueue() DurableProducerQueue {
+	return &inMemoryDurableQueue{state: DurableProducerState{CurrentSeqNr: 1}}
+}
+
+func (q *inMemoryDurableQueue) LoadState() DurableProducerState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state := q.state
+	state.Unconfirmed = append([]DurableMessage(nil), q.state.Unconfirmed...)
+	return state
+}
+
+func (q *inMemoryDurableQueue) StoreMessageSent(msg DurableMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.state.Unconfirmed = append(q.state.Unconfirmed, msg)
+	q.state.CurrentSeqNr = msg.SeqNr + 1
+}
+
+func (q *inMemoryDurableQueue) StoreMessageConfirmed(seqNr uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if seqNr <= q.state.HighestConfirmedSeqNr {
+		return
+	}
+	q.state.HighestConfirmedSeqNr = seqNr
+	for len(q.state.Unconfirmed) > 0 && q.state.Unconfirmed[0].SeqNr <= seqNr {
+		q.state.Unconfirmed = q.state.Unconfirmed[1:]
+	}
+}
+
+type sequencedMessage struct {
+	producerID string
+	seqNr      uint64
+	msg        interface{}
+}
+
+type registerProducer struct {
+	producerID string
+	fromSeqNr  uint64
+}
+
+// deliveryRequest acknowledges messages up to confirmedSeqNr and allows sending messages up to requestUpToSeqNr.
+type deliveryRequest struct {
+	confirmedSeqNr   uint64
+	requestUpToSeqNr uint64
+}
+
+type deliveryResend struct {
+	fromSeqNr uint64
+}
+
+type resendTick struct{}
+
+// ProducerController provides at-least-once delivery of the messages sent by the producer to a consumer
+// controller. Messages are numbered, resent until confirmed and sent only within the window requested
+// by the consumer side. The queue can be nil if messages don't need to survive producer controller crashes.
+func ProducerController(producerID string, queue DurableProducerQueue, settings DeliverySettings) SetupHandler {
+	settings = settings.withDefaults()
+
+	return func(ctx ActorContext) MessageHandler {
+		ctx.DeliverSignals(true)
+
+		state := DurableProducerState{CurrentSeqNr: 1}
+		if queue != nil {
+			state = queue.LoadState()
+		}
+		currentSeqNr := state.CurrentSeqNr
+		confirmedSeqNr := state.HighestConfirmedSeqNr
+		var unconfirmed []sequencedMessage
+		for _, m := range state.Unconfirmed {
+			unconfirmed = append(unconfirmed, sequencedMessage{producerID: producerID, seqNr: m.SeqNr, msg: m.Message})
+		}
+
+		var producer, consumer ActorRef
+		var requestUpToSeqNr uint64
+		requested := false
+		progress := false
+		tick := scheduleOnce(ctx, settings.ResendInterval, resendTick{})
+
+		requestNext := func() {
+			if producer != nil && !requested && currentSeqNr <= requestUpToSeqNr {
+				requested = true
+				producer.Tell(ctx, RequestNext{ProducerID: producerID, CurrentSeqNr: currentSeqNr, SendNextTo: ctx.Self()})
+			}
+		}
+
+		resend := func(fromSeqNr uint64) {
+			if consumer == nil {
+				return
+			}
+			for _, m := range unconfirmed {
+				if m.seqNr >= fromSeqNr {
+					consumer.Tell(ctx, m)
+				}
+			}
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case PostInitSignal, PreStopSignal:
+			case PostStopSignal:
+				tick.Stop()
+			case ProducerStart:
+				producer = m.Producer
+				requestNext()
+			case RegisterConsumer:
+				consumer = m.ConsumerController
+				consumer.Tell(ctx, registerProducer{producerID: producerID, fromSeqNr: confirmedSeqNr + 1})
+				// messages loaded from the durable queue
+				resend(confirmedSeqNr + 1)
+			case deliveryRequest:
+				progress = true
+				if m.confirmedSeqNr > confirmedSeqNr {
+					confirmedSeqNr = m.confirmedSeqNr
+					for len(unconfirmed) > 0 && unconfirmed[0].seqNr <= confirmedSeqNr {
+						unconfirmed = unconfirmed[1:]
+					}
+					if queue != nil {
+						queue.StoreMessageConfirmed(confirmedSeqNr)
+					}
+				}
+				if m.requestUpToSeqNr > requestUpToSeqNr {
+					requestUpToSeqNr = m.requestUpToSeqNr
+				}
+				requestNext()
+			case deliveryResend:
+				resend(m.fromSeqNr)
+			case resendTick:
+				if !progress {
+					resend(confirmedSeqNr + 1)
+				}
+				progress = false
+				tick = scheduleOnce(ctx, settings.ResendInterval, resendTick{})
+			default:
+				seq := sequencedMessage{producerID: producerID, seqNr: currentSeqNr, msg: msg}
+				currentSeqNr++
+				requested = false
+				if queue != nil {
+					queue.StoreMessageSent(DurableMessage{SeqNr: seq.seqNr, Message: msg})
+				}
+				unconfirmed = append(unconfirmed, seq)
+				if consumer != nil {
+					consumer.Tell(ctx, seq)
+				}
+				requestNext()
+			}
+			return nil
+		}
+	}
+}
+
+// ConsumerController delivers messages received from a producer controller to the consumer one at a time,
+// in order and without duplicates.
+func ConsumerController(settings DeliverySettings) SetupHandler {
+	settings = settings.withDefaults()
+
+	return func(ctx ActorContext) MessageHandler {
+		var consumer, producerController ActorRef
+		producerID := ""
+		var expectedSeqNr, confirmedSeqNr uint64
+		var buffer []sequencedMessage
+		delivering := false
+
+		request := func() {
+			producerController.Tell(ctx, deliveryRequest{
+				confirmedSeqNr:   confirmedSeqNr,
+				requestUpToSeqNr: confirmedSeqNr + uint64(settings.WindowSize),
+			})
+		}
+
+		reset := func(fromSeqNr uint64) {
+			expectedSeqNr = fromSeqNr
+			confirmedSeqNr = fromSeqNr - 1
+			buffer = nil
+			delivering = false
+		}
+
+		deliverNext := func() {
+			if consumer == nil || delivering || len(buffer) == 0 {
+				return
+			}
+			delivering = true
+			m := buffer[0]
+			consumer.Tell(ctx, Delivery{ProducerID: m.producerID, SeqNr: m.seqNr, Message: m.msg, ConfirmTo: ctx.Self()})
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case ConsumerStart:
+				consumer = m.Consumer
+				deliverNext()
+			case registerProducer:
+				producerController = ctx.Sender()
+				// a restarted producer controller starts again from its confirmed or the first message
+				if m.producerID != producerID || m.fromSeqNr < expectedSeqNr {
+					producerID = m.producerID
+					reset(m.fromSeqNr)
+				}
+				request()
+			case sequencedMessage:
+				if m.producerID != producerID {
+					return nil
+				}
+				if m.seqNr == 1 && expectedSeqNr > 1 && ctx.Sender() != producerController {
+					// restarted producer controller whose registration was lost
+					reset(1)
+				}
+				producerController = ctx.Sender()
+				if m.seqNr < expectedSeqNr {
+					// duplicate, acknowledge again in case the previous request was lost
+					request()
+					return nil
+				}
+				if m.seqNr > expectedSeqNr {
+					producerController.Tell(ctx, deliveryResend{fromSeqNr: expectedSeqNr})
+					return nil
+				}
+				expectedSeqNr++
+				buffer = append(buffer, m)
+				deliverNext()
+			case Confirmed:
+				if !delivering {
+					return nil
+				}
+				delivering = false
+				confirmedSeqNr = buffer[0].seqNr
+				buffer = buffer[1:]
+				request()
+				deliverNext()
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package tractor
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func numberProducer(count int) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		next := 0
+		return func(msg interface{}) MessageHandler {
+			if r, ok := msg.(RequestNext); ok && next < count {
+				r.SendNextTo.Tell(ctx, next)
+				next++
+			}
+			return nil
+		}
+	}
+}
+
+func collectingConsumer(count int, received chan []interface{}) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		var messages []interface{}
+		return func(msg interface{}) MessageHandler {
+			if d, ok := msg.(Delivery); ok {
+				messages = append(messages, d.Message)
+				d.ConfirmTo.Tell(ctx, Confirmed{})
+				if len(messages) == count {
+					received <- messages
+					return Stopped()
+				}
+			}
+			if msg == "received" {
+				ctx.Sender().Tell(ctx, append([]interface{}(nil), messages...))
+			}
+			return nil
+		}
+	}
+}
+
+func numbers(count int) []interface{} {
+	var result []interface{}
+	for i := 0; i < count; i++ {
+		result = append(result, i)
+	}
+	return result
+}
+
+var _ = Describe("Reliable delivery", func() {
+	It("delivers all messages in order", func() {
+		received := make(chan []interface{}, 1)
+		root := func(ctx ActorContext) MessageHandler {
+			settings := DeliverySettings{WindowSize: 3}
+			consumerController := ctx.Spawn(ConsumerController(settings))
+			consumer := ctx.Spawn(collectingConsumer(50, received))
+			consumerController.Tell(ctx, ConsumerStart{Consumer: consumer})
+
+			producerController := ctx.Spawn(ProducerController("p", nil, settings))
+			producerController.Tell(ctx, ProducerStart{Producer: ctx.Spawn(numberProducer(50))})
+			producerController.Tell(ctx, RegisterConsumer{ConsumerController: consumerController})
+
+			ctx.Watch(consumer)
+			return func(msg interface{}) MessageHandler {
+				return Stopped()
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(<-received).To(Equal(numbers(50)))
+	})
+
+	It("drops duplicates", func() {
+		received := make(chan []interface{}, 1)
+		root := func(ctx ActorContext) MessageHandler {
+			consumerController := ctx.Spawn(ConsumerController(DeliverySettings{}))
+			consumer := ctx.Spawn(collectingConsumer(2, received))
+			consumerController.Tell(ctx, ConsumerStart{Consumer: consumer})
+
+			consumerController.Tell(ctx, registerProducer{producerID: "p", fromSeqNr: 1})
+			consumerController.Tell(ctx, sequencedMessage{producerID: "p", seqNr: 1, msg: "a"})
+			consumerController.Tell(ctx, sequencedMessage{producerID: "p", seqNr: 1, msg: "a"})
+			consumerController.Tell(ctx, sequencedMessage{producerID: "p", seqNr: 2, msg: "b"})
+
+			ctx.Watch(consumer)
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(<-received).To(Equal([]interface{}{"a", "b"}))
+	})
+
+	It("resends unconfirmed messages from the durable queue after a producer crash", func() {
+		received := make(chan []interface{}, 1)
+		queue := NewInMemoryDurableQueue()
+		root := func(ctx ActorContext) MessageHandler {
+			settings := DeliverySettings{WindowSize: 5}
+			consumerController := ctx.Spawn(ConsumerController(settings))
+
+			// the first producer controller sends messages that are never confirmed and is abandoned
+			crashing := ctx.Spawn(ProducerController("p", queue, settings))
+			crashing.Tell(ctx, ProducerStart{Producer: ctx.Spawn(numberProducer(3))})
+			crashing.Tell(ctx, RegisterConsumer{ConsumerController: ctx.Self()})
+			crashing.Tell(ctx, deliveryRequest{confirmedSeqNr: 0, requestUpToSeqNr: 5})
+
+			return func(msg interface{}) MessageHandler {
+				if m, ok := msg.(sequencedMessage); ok && m.seqNr == 3 {
+					consumer := ctx.Spawn(collectingConsumer(6, received))
+					consumerController.Tell(ctx, ConsumerStart{Consumer: consumer})
+					producerController := ctx.Spawn(ProducerController("p", queue, settings))
+					producerController.Tell(ctx, ProducerStart{Producer: ctx.Spawn(numberProducer(3))})
+					producerController.Tell(ctx, RegisterConsumer{ConsumerController: consumerController})
+					ctx.Watch(consumer)
+				}
+				if _, ok := msg.(Terminated); ok {
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(<-received).To(Equal([]interface{}{0, 1, 2, 0, 1, 2}))
+	})
+
+	It("starts over when a producer controller restarts with the same id", func() {
+		received := make(chan []interface{}, 1)
+		root := func(ctx ActorContext) MessageHandler {
+			consumerController := ctx.Spawn(ConsumerController(DeliverySettings{}))
+			consumer := ctx.Spawn(collectingConsumer(6, received))
+			consumerController.Tell(ctx, ConsumerStart{Consumer: consumer})
+
+			first := ctx.Spawn(ProducerController("p", nil, DeliverySettings{}))
+			first.Tell(ctx, ProducerStart{Producer: ctx.Spawn(numberProducer(3))})
+			first.Tell(ctx, RegisterConsumer{ConsumerController: consumerController})
+			Eventually(func() int {
+				return len((<-ctx.Ask(consumer, "received")).([]interface{}))
+			}).Should(Equal(3))
+			ctx.Stop(first)
+
+			second := ctx.Spawn(ProducerController("p", nil, DeliverySettings{}))
+			second.Tell(ctx, ProducerStart{Producer: ctx.Spawn(numberProducer(3))})
+			second.Tell(ctx, RegisterConsumer{ConsumerController: consumerController})
+
+			ctx.Watch(consumer)
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(<-received).To(Equal([]interface{}{0, 1, 2, 0, 1, 2}))
+	})
+})
@@ -0,0 +1,260 @@
+package tractor
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+const defaultThroughput = 5
+
+// Dispatcher decides where and when actors process their mailboxes.
+type Dispatcher interface {
+	// attach is called once when the actor is spawned.
+	attach(actor *localActorContext)
+	// schedule is called when an idle actor receives a message or a command.
+	schedule(actor *localActorContext)
+	// mailboxCapacity returns the capacity of mailboxes of the attached actors, zero means unbounded.
+	mailboxCapacity() int
+}
+
+// dedicatedDispatcher runs every actor on its own goroutine.
+type dedicatedDispatcher struct{}
+
+func (dedicatedDispatcher) attach(actor *localActorContext) {
+	runDedicated(actor, false)
+}
+
+func runDedicated(actor *localActorContext, lockOSThread bool) {
+	actor.wake = make(chan struct{}, 1)
+	go func() {
+		if lockOSThread {
+			runtime.LockOSThread()
+		}
+		for range actor.wake {
+			actor.run(0)
+			if actor.terminated {
+				return
+			}
+		}
+	}()
+}
+
+func (dedicatedDispatcher) schedule(actor *localActorContext) {
+	wakeDedicated(actor)
+}
+
+func wakeDedicated(actor *localActorContext) {
+	select {
+	case actor.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (dedicatedDispatcher) mailboxCapacity() int {
+	return defaultMailboxSize
+}
+
+// pinnedDispatcher runs every actor on its own goroutine, optionally locked to its OS thread.
+type pinnedDispatcher struct {
+	lockOSThread bool
+}
+
+// NewPinnedDispatcher creates a dispatcher for actors that block or depend on thread local state of cgo
+// libraries. Every actor gets its own goroutine, locked to its OS thread if lockOSThread is true. The thread exits
+// with the actor.
+func NewPinnedDispatcher(lockOSThread bool) Dispatcher {
+	return &pinnedDispatcher{lockOSThread: lockOSThread}
+}
+
+func (d *pinnedDispatcher) attach(actor *localActorContext) {
+	runDedicated(actor, d.lockOSThread)
+}
+
+func (d *pinnedDispatcher) schedule(actor *localActorContext) {
+	wakeDedicated(actor)
+}
+
+func (d *pinnedDispatcher) mailboxCapacity() int {
+	return defaultMailboxSize
+}
+
+// PoolDispatcher runs actors on a bounded pool of goroutines. Actors only occupy a goroutine while they have
+// messages, and process at most throughput messages before yielding to other actors. Mailboxes are bounded, but
+// actors of a pool exceed the bound instead of waiting for space, since they would hold on to a goroutine of the
+// pool. Other senders, like FromChannel, wait.
+//
+// A handler blocking on a reply, like <-ctx.Ask(ref, msg), holds its goroutine until the reply arrives. The reply
+// is received on a goroutine of its own, but an asked actor on the same pool needs another goroutine of the pool,
+// so asking it deadlocks a pool of size 1.
+type PoolDispatcher struct {
+	size       int
+	throughput int
+
+	mu      sync.Mutex
+	queue   []*localActorContext
+	workers int
+}
+
+// NewPoolDispatcher creates a dispatcher with at most size goroutines, runtime.GOMAXPROCS(0) if size is zero.
+// Throughput of zero means 5 messages.
+func NewPoolDispatcher(size int, throughput int) *PoolDispatcher {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	if throughput <= 0 {
+		throughput = defaultThroughput
+	}
+	return &PoolDispatcher{size: size, throughput: throughput}
+}
+
+func (p *PoolDispatcher) attach(*localActorContext) {}
+
+func (p *PoolDispatcher) schedule(actor *localActorContext) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, actor)
+	if p.workers < p.size {
+		p.workers++
+		go p.work()
+	}
+}
+
+func (p *PoolDispatcher) mailboxCapacity() int {
+	return defaultMailboxSize
+}
+
+// work runs scheduled actors until there are none.
+func (p *PoolDispatcher) work() {
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		actor := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+
+		actor.run(p.throughput)
+	}
+}
+
+// NewBlockingIODispatcher creates a pool of size goroutines for actors calling blocking functions, so that they
+// don't starve actors on other dispatchers. Every actor handles one message per turn.
+func NewBlockingIODispatcher(size int) *PoolDispatcher {
+	return NewPoolDispatcher(size, 1)
+}
+
+// DeterministicDispatcher runs actors one message at a time on the goroutine calling Step, RunUntilIdle or
+// ActorSystem.Wait. Both the next actor and the next message among the oldest messages of each sender in its
+// mailbox are chosen by a seeded random generator, so the same seed reproduces the same interleaving of messages
+// as long as the messages sent from outside of the system arrive in the same order. Every choice is recorded in
+// the schedule that can be replayed with NewReplayDispatcher.
+// Handlers must not block waiting for other actors. Mailboxes are unbounded.
+type DeterministicDispatcher struct {
+	mu    sync.Mutex
+	rand  *rand.Rand
+	ready []*localActorContext
+	wake  chan struct{}
+
+	// replay is the rest of the replayed schedule if rand is nil
+	replay  []int
+	choices []int
+}
+
+func NewDeterministicDispatcher(seed int64) *DeterministicDispatcher {
+	return &DeterministicDispatcher{rand: rand.New(rand.NewSource(seed)), wake: make(chan struct{}, 1)}
+}
+
+// NewReplayDispatcher creates a dispatcher making the choices of the schedule. The first candidate is chosen
+// once the schedule is exhausted.
+func NewReplayDispatcher(schedule []int) *DeterministicDispatcher {
+	return &DeterministicDispatcher{replay: schedule, wake: make(chan struct{}, 1)}
+}
+
+func (d *DeterministicDispatcher) attach(actor *localActorContext) {
+	actor.mailbox.choose = d.choose
+}
+
+func (d *DeterministicDispatcher) schedule(actor *localActorContext) {
+	d.mu.Lock()
+	d.ready = append(d.ready, actor)
+	d.mu.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *DeterministicDispatcher) mailboxCapacity() int {
+	return 0
+}
+
+// choose returns one of n candidates. Only the choices between several candidates are recorded.
+func (d *DeterministicDispatcher) choose(n int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.chooseLocked(n)
+}
+
+func (d *DeterministicDispatcher) chooseLocked(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	choice := 0
+	if d.rand != nil {
+		choice = d.rand.Intn(n)
+	} else if len(d.replay) > 0 {
+		choice = d.replay[0] % n
+		d.replay = d.replay[1:]
+	}
+	d.choices = append(d.choices, choice)
+	return choice
+}
+
+// Schedule returns the choices made so far.
+func (d *DeterministicDispatcher) Schedule() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]int(nil), d.choices...)
+}
+
+// Step delivers one message to a randomly chosen actor. It returns false if no actor has anything to do.
+func (d *DeterministicDispatcher) Step() bool {
+	d.mu.Lock()
+	if len(d.ready) == 0 {
+		d.mu.Unlock()
+		return false
+	}
+	i := d.chooseLocked(len(d.ready))
+	actor := d.ready[i]
+	d.ready = append(d.ready[:i], d.ready[i+1:]...)
+	d.mu.Unlock()
+
+	actor.run(1)
+	return true
+}
+
+// RunUntilIdle steps until no actor has anything to do and returns the number of steps.
+func (d *DeterministicDispatcher) RunUntilIdle() int {
+	steps := 0
+	for d.Step() {
+		steps++
+	}
+	return steps
+}
+
+// runUntil steps until done is closed, waiting for messages from outside of the system when idle.
+func (d *DeterministicDispatcher) runUntil(done <-chan struct{}) {
+	for {
+		d.RunUntilIdle()
+		select {
+		case <-done:
+			return
+		case <-d.wake:
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package tractor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// interleaving records the order in which messages of 3 concurrent senders are received.
+func interleaving(seed int64) []string {
+	return interleavingWith(NewDeterministicDispatcher(seed))
+}
+
+func interleavingWith(d *DeterministicDispatcher) []string {
+	var received []string
+	system := Start(func(ctx ActorContext) MessageHandler {
+		recorder := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				received = append(received, msg.(string))
+				if len(received) == 15 {
+					return Stopped()
+				}
+				return nil
+			}
+		})
+		for i := 0; i < 3; i++ {
+			id := i
+			ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				ctx.Self().Tell(ctx, 0)
+				return func(msg interface{}) MessageHandler {
+					n := msg.(int)
+					recorder.Tell(ctx, fmt.Sprintf("%d-%d", id, n))
+					if n == 4 {
+						return Stopped()
+					}
+					ctx.Self().Tell(ctx, n+1)
+					return nil
+				}
+			})
+		}
+		ctx.Watch(recorder)
+		return func(msg interface{}) MessageHandler {
+			return Stopped()
+		}
+	}, WithDispatcher(d))
+	system.Wait()
+	return received
+}
+
+var _ = Describe("DeterministicDispatcher", func() {
+	It("reproduces interleavings from the seed", func() {
+		Expect(interleaving(42)).To(Equal(interleaving(42)))
+
+		distinct := map[string]bool{}
+		for seed := int64(0); seed < 10; seed++ {
+			received := interleaving(seed)
+			Expect(received).To(HaveLen(15))
+			for id := 0; id < 3; id++ {
+				var fromSender []string
+				for _, msg := range received {
+					if msg[0] == byte('0'+id) {
+						fromSender = append(fromSender, msg)
+					}
+				}
+				Expect(fromSender).To(Equal([]string{
+					fmt.Sprintf("%d-0", id), fmt.Sprintf("%d-1", id), fmt.Sprintf("%d-2", id),
+					fmt.Sprintf("%d-3", id), fmt.Sprintf("%d-4", id),
+				}))
+			}
+			distinct[fmt.Sprint(received)] = true
+		}
+		Expect(len(distinct)).To(BeNumerically(">", 1))
+	})
+
+	It("runs actors only when stepped", func() {
+		d := NewDeterministicDispatcher(0)
+		received := 0
+		system := Start(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				received++
+				return nil
+			}
+		}, WithDispatcher(d))
+		system.Root().Tell(system.Context(), "a")
+		system.Root().Tell(system.Context(), "b")
+		Expect(received).To(Equal(0))
+		Expect(d.Step()).To(BeTrue())
+		Expect(received).To(Equal(1))
+		Expect(d.RunUntilIdle()).To(Equal(1))
+		Expect(received).To(Equal(2))
+		Expect(d.Step()).To(BeFalse())
+	})
+})
+
+var _ = Describe("PoolDispatcher", func() {
+	It("runs many actors on a bounded number of goroutines", func() {
+		pool := NewPoolDispatcher(2, 0)
+		var mu sync.Mutex
+		running, maxRunning, received := 0, 0, 0
+		system := Start(func(ctx ActorContext) MessageHandler {
+			for i := 0; i < 100; i++ {
+				ref := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+					return func(msg interface{}) MessageHandler {
+						mu.Lock()
+						running++
+						if running > maxRunning {
+							maxRunning = running
+						}
+						mu.Unlock()
+						time.Sleep(time.Millisecond)
+						mu.Lock()
+						running--
+						received++
+						mu.Unlock()
+						return Stopped()
+					}
+				}, OnDispatcher(pool))
+				ref.Tell(ctx, "hello")
+			}
+			return func(msg interface{}) MessageHandler {
+				return Stopped()
+			}
+		})
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return received
+		}).Should(Equal(100))
+		Expect(maxRunning).To(BeNumerically("<=", 2))
+		system.Root().Tell(system.Context(), "stop")
+		system.Wait()
+	})
+
+	It("yields to other actors after throughput messages", func() {
+		pool := NewPoolDispatcher(1, 1)
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var log []string
+		record := func(entry string) {
+			mu.Lock()
+			defer mu.Unlock()
+			log = append(log, entry)
+		}
+		system := Start(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				if msg == "first" {
+					<-release
+				}
+				record(msg.(string))
+				return nil
+			}
+		}, WithDispatcher(pool))
+		other := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				record(msg.(string))
+				return nil
+			}
+		})
+		system.Root().Tell(system.Context(), "first")
+		system.Root().Tell(system.Context(), "second")
+		other.Tell(system.Context(), "other")
+		close(release)
+		Eventually(func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), log...)
+		}).Should(Equal([]string{"first", "other", "second"}))
+	})
+
+	It("receives replies to Ask on a pool of size 1", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			echo := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					ctx.Sender().Tell(ctx, msg)
+					return nil
+				}
+			}, OnDispatcher(NewPinnedDispatcher(false)))
+			Expect(<-ctx.Ask(echo, "hello")).To(Equal("hello"))
+			return Stopped()
+		}, WithDispatcher(NewPoolDispatcher(1, 0)))
+		system.Wait()
+	})
+
+	It("doesn't wait for space in full mailboxes of the pool", func() {
+		received := 0
+		system := Start(func(ctx ActorContext) MessageHandler {
+			counter := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					received++
+					if received == 2*defaultMailboxSize {
+						return Stopped()
+					}
+					return nil
+				}
+			})
+			// the root holds the only goroutine of the pool until all messages are sent
+			for i := 0; i < 2*defaultMailboxSize; i++ {
+				counter.Tell(ctx, i)
+			}
+			ctx.Watch(counter)
+			return func(msg interface{}) MessageHandler {
+				return Stopped()
+			}
+		}, WithDispatcher(NewPoolDispatcher(1, 0)))
+		system.Wait()
+		Expect(received).To(Equal(2 * defaultMailboxSize))
+	})
+})
+
+var _ = Describe("Blocking actors", func() {
+	sleeper := func(ctx ActorContext) MessageHandler {
+		return func(msg interface{}) MessageHandler {
+			time.Sleep(msg.(time.Duration))
+			ctx.Sender().Tell(ctx, "done")
+			return nil
+		}
+	}
+
+	It("warns about slow handlers on the system dispatcher", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			events, ch := ToChannel(ctx, 10)
+			ctx.EventStream().Subscribe(events, SlowHandler{})
+			slow := ctx.Spawn(sleeper)
+			pinned := ctx.Spawn(sleeper, OnDispatcher(NewPinnedDispatcher(true)))
+			blocking := ctx.Spawn(sleeper, OnDispatcher(NewBlockingIODispatcher(2)))
+
+			Expect(<-ctx.Ask(slow, time.Duration(0))).To(Equal("done"))
+			Expect(<-ctx.Ask(pinned, 20*time.Millisecond)).To(Equal("done"))
+			Expect(<-ctx.Ask(blocking, 20*time.Millisecond)).To(Equal("done"))
+			Expect(<-ctx.Ask(slow, 20*time.Millisecond)).To(Equal("done"))
+
+			event := (<-ch).(SlowHandler)
+			Expect(event.Actor).To(Equal(slow))
+			Expect(event.Message).To(Equal(20 * time.Millisecond))
+			Expect(event.Duration).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(ch).To(BeEmpty())
+			return Stopped()
+		}, WarnSlowHandlers(10*time.Millisecond))
+		system.Wait()
+	})
+
+	It("don't starve actors on other dispatchers", func() {
+		io := NewBlockingIODispatcher(1)
+		system := Start(func(ctx ActorContext) MessageHandler {
+			blocking := ctx.Spawn(sleeper, OnDispatcher(io))
+			other := ctx.Spawn(sleeper, OnDispatcher(NewPoolDispatcher(1, 0)))
+			blocked := ctx.Ask(blocking, 200*time.Millisecond)
+			Expect(<-ctx.Ask(other, time.Duration(0))).To(Equal("done"))
+			Expect(blocked).To(BeEmpty())
+			Expect(<-blocked).To(Equal("done"))
+			return Stopped()
+		})
+		system.Wait()
+	})
+})
@@ -0,0 +1,93 @@
+package tractor
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// EventStream publishes system events to subscribed actors.
+type EventStream interface {
+	// Subscribe delivers published events of the same type as the sample to the subscriber.
+	// Terminated local subscribers are unsubscribed automatically.
+	Subscribe(subscriber ActorRef, sample interface{})
+	Unsubscribe(subscriber ActorRef)
+	Publish(event interface{})
+}
+
+// UnhandledMessage is published when a handler returns Unhandled().
+type UnhandledMessage struct {
+	Message   interface{}
+	Sender    ActorRef
+	Recipient ActorRef
+}
+
+// DeadLetter is published for messages that couldn't be delivered.
+type DeadLetter struct {
+	Message   interface{}
+	Sender    ActorRef
+	Recipient ActorRef
+}
+
+// SlowHandler is published when a handler of an actor on the system dispatcher runs longer than the threshold
+// set with WarnSlowHandlers.
+type SlowHandler struct {
+	Actor    ActorRef
+	Message  interface{}
+	Duration time.Duration
+}
+
+type eventStream struct {
+	ctx         ActorContext
+	mu          sync.Mutex
+	subscribers map[reflect.Type][]ActorRef
+}
+
+func newEventStream(ctx ActorContext) *eventStream {
+	return &eventStream{ctx: ctx, subscribers: map[reflect.Type][]ActorRef{}}
+}
+
+func (s *eventStream) Subscribe(subscriber ActorRef, sample interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := reflect.TypeOf(sample)
+	for _, ref := range s.subscribers[t] {
+		if ref == subscriber {
+			return
+		}
+	}
+	s.subscribers[t] = append(s.subscribers[t], subscriber)
+	if done := doneOf(subscriber); done != nil {
+		go func() {
+			<-done
+			s.Unsubscribe(subscriber)
+		}()
+	}
+}
+
+func (s *eventStream) Unsubscribe(subscriber ActorRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, refs := range s.subscribers {
+		if refs = removeRef(refs, subscriber); len(refs) > 0 {
+			s.subscribers[t] = refs
+		} else {
+			delete(s.subscribers, t)
+		}
+	}
+}
+
+func (s *eventStream) Publish(event interface{}) {
+	s.publish(event)
+}
+
+// publish returns the number of subscribers the event was delivered to.
+func (s *eventStream) publish(event interface{}) int {
+	s.mu.Lock()
+	refs := append([]ActorRef(nil), s.subscribers[reflect.TypeOf(event)]...)
+	s.mu.Unlock()
+	for _, ref := range refs {
+		ref.Tell(s.ctx, event)
+	}
+	return len(refs)
+}
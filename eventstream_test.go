@@ -0,0 +1,77 @@
+package tractor
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func ignoringInts(ctx ActorContext) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		switch msg.(type) {
+		case int:
+			return Unhandled()
+		case bool:
+			return Stopped()
+		}
+		ctx.Sender().Tell(ctx, msg)
+		return nil
+	}
+}
+
+var _ = Describe("Event stream", func() {
+	It("publishes unhandled messages", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			events, ch := ToChannel(ctx, 10)
+			ctx.EventStream().Subscribe(events, UnhandledMessage{})
+			actor := ctx.Spawn(ignoringInts)
+			actor.Tell(ctx, 42)
+			Expect(<-ctx.Ask(actor, "still alive")).To(Equal("still alive"))
+			Expect(<-ch).To(Equal(UnhandledMessage{Message: 42, Sender: ctx.Self(), Recipient: actor}))
+			Expect(ch).To(BeEmpty())
+			actor.Tell(ctx, true)
+			return Stopped()
+		})
+		system.Wait()
+	})
+
+	It("optionally publishes unhandled messages as dead letters", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			events, ch := ToChannel(ctx, 10)
+			ctx.EventStream().Subscribe(events, DeadLetter{})
+			actor := ctx.Spawn(ignoringInts)
+			actor.Tell(ctx, 42)
+			Expect(<-ch).To(Equal(DeadLetter{Message: 42, Sender: ctx.Self(), Recipient: actor}))
+			actor.Tell(ctx, true)
+			return Stopped()
+		}, PublishUnhandledAsDeadLetters())
+		system.Wait()
+	})
+
+	It("unsubscribes terminated actors", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			stream := ctx.EventStream().(*eventStream)
+			subscriber := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					if msg == true {
+						return Stopped()
+					}
+					return nil
+				}
+			})
+			stream.Subscribe(subscriber, "")
+			stream.Subscribe(subscriber, "")
+			Expect(stream.publish("event")).To(Equal(1))
+			stream.Unsubscribe(subscriber)
+			Expect(stream.publish("event")).To(Equal(0))
+
+			stream.Subscribe(subscriber, "")
+			ctx.Watch(subscriber)
+			subscriber.Tell(ctx, true)
+			return func(msg interface{}) MessageHandler {
+				Eventually(func() int { return stream.publish("event") }).Should(Equal(0))
+				return Stopped()
+			}
+		})
+		system.Wait()
+	})
+})
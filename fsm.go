@@ -0,0 +1,209 @@
+package tractor
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// StateTimeout is delivered to the current state when it didn't receive any message for its timeout.
+type StateTimeout struct{}
+
+// FSMHandler handles a message in a state given the current state data and returns the transition to make.
+type FSMHandler func(msg interface{}, data interface{}) FSMTransition
+
+// FSMTransition is created by FSM.Goto, FSM.Stay or FSM.Stop.
+type FSMTransition struct {
+	state   string
+	data    interface{}
+	setData bool
+	timeout *time.Duration
+	stay    bool
+	stop    bool
+}
+
+// Using replaces the state data.
+func (t FSMTransition) Using(data interface{}) FSMTransition {
+	t.data = data
+	t.setData = true
+	return t
+}
+
+// ForMax overrides the timeout of the target state for this transition. Zero disables the timeout.
+func (t FSMTransition) ForMax(timeout time.Duration) FSMTransition {
+	t.timeout = &timeout
+	return t
+}
+
+// FSMState declares the handlers of a single state.
+type FSMState struct {
+	name     string
+	timeout  time.Duration
+	handlers map[reflect.Type]FSMHandler
+	any      FSMHandler
+}
+
+// On handles messages of the same type as the sample.
+func (s *FSMState) On(sample interface{}, handler FSMHandler) *FSMState {
+	s.handlers[reflect.TypeOf(sample)] = handler
+	return s
+}
+
+// OnAny handles messages that don't have a type specific handler.
+func (s *FSMState) OnAny(handler FSMHandler) *FSMState {
+	s.any = handler
+	return s
+}
+
+// Timeout delivers StateTimeout when the state doesn't receive a message for the duration.
+func (s *FSMState) Timeout(timeout time.Duration) *FSMState {
+	s.timeout = timeout
+	return s
+}
+
+func (s *FSMState) handler(msg interface{}) FSMHandler {
+	if handler, ok := s.handlers[reflect.TypeOf(msg)]; ok {
+		return handler
+	}
+	return s.any
+}
+
+type fsmTimeout struct {
+	generation int
+}
+
+// FSM builds a message handler out of named states. It is meant to be created in the setup handler:
+//
+//	fsm := NewFSM(ctx, "idle", nil)
+//	fsm.When("idle").On(start{}, func(msg, data interface{}) FSMTransition {
+//		return fsm.Goto("running").Using(msg)
+//	})
+//	return fsm.Start()
+type FSM struct {
+	ctx         ActorContext
+	states      map[string]*FSMState
+	state       *FSMState
+	data        interface{}
+	unhandled   FSMHandler
+	transitions []func(from, to string)
+	timer       Cancellable
+	generation  int
+}
+
+func NewFSM(ctx ActorContext, initialState string, initialData interface{}) *FSM {
+	fsm := &FSM{ctx: ctx, states: map[string]*FSMState{}, data: initialData}
+	fsm.state = fsm.When(initialState)
+	return fsm
+}
+
+// When returns the declaration of the named state, creating it if needed.
+func (fsm *FSM) When(name string) *FSMState {
+	state, ok := fsm.states[name]
+	if !ok {
+		state = &FSMState{name: name, handlers: map[reflect.Type]FSMHandler{}}
+		fsm.states[name] = state
+	}
+	return state
+}
+
+// WhenUnhandled handles messages that the current state doesn't handle. Such messages are reported as unhandled
+// by default.
+func (fsm *FSM) WhenUnhandled(handler FSMHandler) *FSM {
+	fsm.unhandled = handler
+	return fsm
+}
+
+// OnTransition registers a hook that runs after leaving a state and before handling the next message.
+// Hooks run for every Goto, including one to the current state, and see the new state data.
+func (fsm *FSM) OnTransition(hook func(from, to string)) *FSM {
+	fsm.transitions = append(fsm.transitions, hook)
+	return fsm
+}
+
+func (fsm *FSM) Goto(state string) FSMTransition {
+	return FSMTransition{state: state}
+}
+
+func (fsm *FSM) Stay() FSMTransition {
+	return FSMTransition{stay: true}
+}
+
+func (fsm *FSM) Stop() FSMTransition {
+	return FSMTransition{stop: true}
+}
+
+// StateName returns the name of the current state.
+func (fsm *FSM) StateName() string {
+	return fsm.state.name
+}
+
+// StateData returns the data of the current state.
+func (fsm *FSM) StateData() interface{} {
+	return fsm.data
+}
+
+// Start returns the handler running the machine from the initial state.
+func (fsm *FSM) Start() MessageHandler {
+	fsm.scheduleTimeout(fsm.state.timeout)
+	return fsm.handle
+}
+
+func (fsm *FSM) handle(msg interface{}) MessageHandler {
+	if timeout, ok := msg.(fsmTimeout); ok {
+		if timeout.generation != fsm.generation {
+			return nil
+		}
+		msg = StateTimeout{}
+	}
+	fsm.cancelTimeout()
+
+	handler := fsm.state.handler(msg)
+	if handler == nil {
+		handler = fsm.unhandled
+	}
+	if handler == nil {
+		fsm.apply(fsm.Stay())
+		return Unhandled()
+	}
+	return fsm.apply(handler(msg, fsm.data))
+}
+
+func (fsm *FSM) apply(t FSMTransition) MessageHandler {
+	if t.setData {
+		fsm.data = t.data
+	}
+	if t.stop {
+		return Stopped()
+	}
+	if !t.stay {
+		next, ok := fsm.states[t.state]
+		if !ok {
+			panic(fmt.Sprintf("fsm: unknown state %q", t.state))
+		}
+		from := fsm.state.name
+		fsm.state = next
+		for _, hook := range fsm.transitions {
+			hook(from, next.name)
+		}
+	}
+	timeout := fsm.state.timeout
+	if t.timeout != nil {
+		timeout = *t.timeout
+	}
+	fsm.scheduleTimeout(timeout)
+	return nil
+}
+
+func (fsm *FSM) scheduleTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		fsm.timer = scheduleOnce(fsm.ctx, timeout, fsmTimeout{generation: fsm.generation})
+	}
+}
+
+func (fsm *FSM) cancelTimeout() {
+	if fsm.timer != nil {
+		fsm.timer.Stop()
+		fsm.timer = nil
+	}
+	fsm.generation++
+}
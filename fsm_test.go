@@ -0,0 +1,150 @@
+package tractor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type pressDigit struct {
+	digit byte
+}
+
+type closeLock struct{}
+
+type getLockState struct{}
+
+type lockState struct {
+	name string
+	data interface{}
+}
+
+func codeLock(code string, openFor time.Duration, transitions *[]string) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		fsm := NewFSM(ctx, "locked", "")
+		fsm.When("locked").On(pressDigit{}, func(msg, data interface{}) FSMTransition {
+			entered := data.(string) + string(msg.(pressDigit).digit)
+			if entered == code {
+				return fsm.Goto("open").Using("")
+			}
+			if len(entered) == len(code) {
+				return fsm.Stay().Using("")
+			}
+			return fsm.Stay().Using(entered)
+		})
+		fsm.When("open").Timeout(openFor).
+			On(StateTimeout{}, func(msg, data interface{}) FSMTransition {
+				return fsm.Goto("locked")
+			}).
+			On(closeLock{}, func(msg, data interface{}) FSMTransition {
+				return fsm.Goto("locked")
+			})
+		fsm.WhenUnhandled(func(msg, data interface{}) FSMTransition {
+			switch msg.(type) {
+			case getLockState:
+				ctx.Sender().Tell(ctx, lockState{name: fsm.StateName(), data: fsm.StateData()})
+				return fsm.Stay()
+			case bool:
+				return fsm.Stop()
+			}
+			return fsm.Stay()
+		})
+		fsm.OnTransition(func(from, to string) {
+			*transitions = append(*transitions, from+"->"+to)
+		})
+		return fsm.Start()
+	}
+}
+
+func press(ctx ActorContext, lock ActorRef, code string) {
+	for i := range code {
+		lock.Tell(ctx, pressDigit{digit: code[i]})
+	}
+}
+
+var _ = Describe("FSM", func() {
+	It("transitions between states carrying data", func() {
+		var transitions []string
+		system := Start(func(ctx ActorContext) MessageHandler {
+			lock := ctx.Spawn(codeLock("123", time.Hour, &transitions))
+			press(ctx, lock, "12")
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "locked", data: "12"}))
+			press(ctx, lock, "4")
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "locked", data: ""}))
+			press(ctx, lock, "123")
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "open", data: ""}))
+			lock.Tell(ctx, pressDigit{digit: '1'})
+			lock.Tell(ctx, closeLock{})
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "locked", data: ""}))
+			lock.Tell(ctx, true)
+			return Stopped()
+		})
+		system.Wait()
+		Expect(transitions).To(Equal([]string{"locked->open", "open->locked"}))
+	})
+
+	It("delivers state timeouts", func() {
+		var transitions []string
+		clock := NewManualClock(time.Now())
+		system := Start(func(ctx ActorContext) MessageHandler {
+			lock := ctx.Spawn(codeLock("1", 20*time.Millisecond, &transitions))
+			press(ctx, lock, "1")
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "open", data: ""}))
+			clock.Advance(19 * time.Millisecond)
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "open", data: ""}))
+			clock.Advance(20 * time.Millisecond)
+			Expect(<-ctx.Ask(lock, getLockState{})).To(Equal(lockState{name: "locked", data: ""}))
+			lock.Tell(ctx, true)
+			return Stopped()
+		}, WithClock(clock))
+		system.Wait()
+		Expect(transitions).To(Equal([]string{"locked->open", "open->locked"}))
+	})
+
+	It("restarts the timeout on every message", func() {
+		fired := make(chan struct{}, 1)
+		clock := NewManualClock(time.Now())
+		system := Start(func(ctx ActorContext) MessageHandler {
+			waiting := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				fsm := NewFSM(ctx, "waiting", nil)
+				fsm.When("waiting").Timeout(50*time.Millisecond).
+					On(StateTimeout{}, func(msg, data interface{}) FSMTransition {
+						fired <- struct{}{}
+						return fsm.Stop()
+					}).
+					OnAny(func(msg, data interface{}) FSMTransition {
+						ctx.Sender().Tell(ctx, msg)
+						return fsm.Stay()
+					})
+				return fsm.Start()
+			})
+			for i := 0; i < 4; i++ {
+				clock.Advance(25 * time.Millisecond)
+				Expect(<-ctx.Ask(waiting, i)).To(Equal(i))
+			}
+			clock.Advance(49 * time.Millisecond)
+			Expect(<-ctx.Ask(waiting, "last")).To(Equal("last"))
+			Expect(fired).NotTo(Receive())
+			clock.Advance(50 * time.Millisecond)
+			Eventually(fired).Should(Receive())
+			return Stopped()
+		}, WithClock(clock))
+		system.Wait()
+	})
+
+	It("overrides the state timeout for a transition", func() {
+		system := Start(func(ctx ActorContext) MessageHandler {
+			fsm := NewFSM(ctx, "a", nil)
+			fsm.When("a").On(true, func(msg, data interface{}) FSMTransition {
+				return fsm.Goto("b").ForMax(10 * time.Millisecond)
+			})
+			fsm.When("b").On(StateTimeout{}, func(msg, data interface{}) FSMTransition {
+				return fsm.Stop()
+			})
+			ctx.Self().Tell(ctx, true)
+			return fsm.Start()
+		})
+		system.Wait()
+	})
+})
@@ -0,0 +1,72 @@
+package tractor
+
+import "fmt"
+
+// FuzzScenario starts a system with the options, waits for it to terminate and returns an error if the outcome is
+// wrong. It must be deterministic apart from the interleaving of messages chosen by the dispatcher.
+type FuzzScenario func(options ...SystemOption) error
+
+type FuzzSettings struct {
+	// Iterations is the number of random schedules to try, 100 by default.
+	Iterations int
+	// Seed is the seed of the first schedule, the following ones use consecutive seeds.
+	Seed int64
+}
+
+// FuzzFailure is a failing schedule. Schedule is shrunk and reproduces Err with ReplaySchedule.
+type FuzzFailure struct {
+	Seed     int64
+	Schedule []int
+	Err      error
+}
+
+func (f *FuzzFailure) Error() string {
+	return fmt.Sprintf("seed %d, schedule %v: %v", f.Seed, f.Schedule, f.Err)
+}
+
+// Fuzz runs the scenario with randomly interleaved messages and returns the first failure or nil.
+func Fuzz(settings FuzzSettings, scenario FuzzScenario) *FuzzFailure {
+	iterations := settings.Iterations
+	if iterations <= 0 {
+		iterations = 100
+	}
+	for i := 0; i < iterations; i++ {
+		seed := settings.Seed + int64(i)
+		dispatcher := NewDeterministicDispatcher(seed)
+		if err := scenario(WithDispatcher(dispatcher)); err != nil {
+			schedule, err := shrinkSchedule(dispatcher.Schedule(), err, scenario)
+			return &FuzzFailure{Seed: seed, Schedule: schedule, Err: err}
+		}
+	}
+	return nil
+}
+
+// ReplaySchedule runs the scenario making the choices of the schedule.
+func ReplaySchedule(schedule []int, scenario FuzzScenario) error {
+	return scenario(WithDispatcher(NewReplayDispatcher(schedule)))
+}
+
+// shrinkSchedule looks for the shortest failing prefix of the schedule and then resets as many choices as possible
+// to the first candidate.
+func shrinkSchedule(schedule []int, err error, scenario FuzzScenario) ([]int, error) {
+	for n := 0; n < len(schedule); n++ {
+		if prefixErr := ReplaySchedule(schedule[:n], scenario); prefixErr != nil {
+			schedule, err = schedule[:n], prefixErr
+			break
+		}
+	}
+	for i := range schedule {
+		if schedule[i] == 0 {
+			continue
+		}
+		candidate := append([]int(nil), schedule...)
+		candidate[i] = 0
+		if candidateErr := ReplaySchedule(candidate, scenario); candidateErr != nil {
+			schedule, err = candidate, candidateErr
+		}
+	}
+	for len(schedule) > 0 && schedule[len(schedule)-1] == 0 {
+		schedule = schedule[:len(schedule)-1]
+	}
+	return schedule, err
+}
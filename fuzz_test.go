@@ -0,0 +1,100 @@
+package tractor
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type counterGet struct{}
+type counterSet struct{ value int }
+type counterIncrement struct{}
+type counterAck struct{}
+
+// counterScenario runs two clients incrementing a counter either with get and set or atomically.
+func counterScenario(atomic bool) FuzzScenario {
+	return func(options ...SystemOption) error {
+		result := 0
+		system := Start(func(ctx ActorContext) MessageHandler {
+			counter := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				value := 0
+				return func(msg interface{}) MessageHandler {
+					switch msg := msg.(type) {
+					case counterGet:
+						ctx.Sender().Tell(ctx, value)
+					case counterSet:
+						value = msg.value
+						ctx.Sender().Tell(ctx, counterAck{})
+					case counterIncrement:
+						value++
+						ctx.Sender().Tell(ctx, counterAck{})
+					}
+					return nil
+				}
+			})
+			for i := 0; i < 2; i++ {
+				ctx.Watch(ctx.Spawn(func(ctx ActorContext) MessageHandler {
+					if atomic {
+						counter.Tell(ctx, counterIncrement{})
+					} else {
+						counter.Tell(ctx, counterGet{})
+					}
+					return func(msg interface{}) MessageHandler {
+						switch msg := msg.(type) {
+						case int:
+							counter.Tell(ctx, counterSet{value: msg + 1})
+						case counterAck:
+							return Stopped()
+						}
+						return nil
+					}
+				}))
+			}
+			terminated := 0
+			return func(msg interface{}) MessageHandler {
+				switch msg := msg.(type) {
+				case Terminated:
+					terminated++
+					if terminated == 2 {
+						counter.Tell(ctx, counterGet{})
+					}
+				case int:
+					result = msg
+					return Stopped()
+				}
+				return nil
+			}
+		}, options...)
+		system.Wait()
+		if result != 2 {
+			return fmt.Errorf("expected 2, got %d", result)
+		}
+		return nil
+	}
+}
+
+var _ = Describe("Fuzz", func() {
+	It("passes correct scenarios", func() {
+		Expect(Fuzz(FuzzSettings{Iterations: 50}, counterScenario(true))).To(BeNil())
+	})
+
+	It("finds and shrinks failing schedules", func() {
+		failure := Fuzz(FuzzSettings{Iterations: 50}, counterScenario(false))
+		Expect(failure).NotTo(BeNil())
+		Expect(failure.Err).To(MatchError("expected 2, got 1"))
+
+		Expect(ReplaySchedule(failure.Schedule, counterScenario(false))).To(MatchError(failure.Err.Error()))
+		original := NewDeterministicDispatcher(failure.Seed)
+		Expect(counterScenario(false)(WithDispatcher(original))).To(HaveOccurred())
+		Expect(len(failure.Schedule)).To(BeNumerically("<=", len(original.Schedule())))
+	})
+
+	It("replays the schedule of a seed", func() {
+		for seed := int64(0); seed < 5; seed++ {
+			d := NewDeterministicDispatcher(seed)
+			Expect(interleaving(seed)).To(Equal(interleavingWith(d)))
+			Expect(interleavingWith(NewReplayDispatcher(d.Schedule()))).To(Equal(interleaving(seed)))
+		}
+	})
+})
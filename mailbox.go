@@ -0,0 +1,276 @@
+package tractor
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// mailbox queues commands and messages of an actor. Commands are taken before messages. The actor is scheduled
+// on its dispatcher when the mailbox receives something while the actor is idle.
+//
+// Messages are pushed to a lock-free queue. The mutex is only taken for commands and by senders waiting for
+// space in a full mailbox.
+type mailbox struct {
+	// count is the number of pushed messages that haven't been taken yet, first for 64-bit alignment
+	count int64
+	// capacity bounds the messages, zero means unbounded
+	capacity int64
+	messages mpscQueue
+	// scheduled is 1 while the actor is running or waiting to run
+	scheduled int32
+	// closed mailboxes don't accept messages
+	closed int32
+
+	mu           sync.Mutex
+	commands     []interface{}
+	pendingCount int32
+	// notFull is closed when a message is taken while senders wait
+	notFull chan struct{}
+	waiters int32
+	// terminated mailboxes don't accept commands either
+	terminated bool
+
+	// choose picks the next message among the oldest messages of each sender, nil takes the oldest one
+	choose func(n int) int
+
+	// pending and stash are only accessed by the actor
+	pending []envelope
+	stash   []envelope
+}
+
+func newMailbox(capacity int) *mailbox {
+	m := &mailbox{capacity: int64(capacity), scheduled: 1}
+	m.messages.init()
+	return m
+}
+
+// push adds the message waiting while the mailbox is full, or exceeding the capacity if wait is false. It returns
+// false if the message wasn't added because the mailbox is closed or abort is closed. schedule is true if the actor
+// has to be scheduled.
+func (m *mailbox) push(e envelope, wait bool, abort <-chan struct{}) (ok bool, schedule bool) {
+	for {
+		if atomic.LoadInt32(&m.closed) != 0 {
+			return false, false
+		}
+		if m.capacity == 0 || atomic.AddInt64(&m.count, 1) <= m.capacity || !wait {
+			break
+		}
+		atomic.AddInt64(&m.count, -1)
+
+		m.mu.Lock()
+		if m.notFull == nil {
+			m.notFull = make(chan struct{})
+		}
+		notFull := m.notFull
+		atomic.StoreInt32(&m.waiters, 1)
+		m.mu.Unlock()
+		if atomic.LoadInt64(&m.count) < m.capacity || atomic.LoadInt32(&m.closed) != 0 {
+			continue
+		}
+
+		select {
+		case <-notFull:
+		case <-abort:
+			return false, false
+		}
+	}
+	if m.capacity == 0 {
+		atomic.AddInt64(&m.count, 1)
+	}
+	m.messages.push(&node{env: e})
+	return true, m.wake()
+}
+
+func (m *mailbox) pushCommand(cmd interface{}) (ok bool, schedule bool) {
+	m.mu.Lock()
+	if m.terminated {
+		m.mu.Unlock()
+		return false, false
+	}
+	m.commands = append(m.commands, cmd)
+	atomic.AddInt32(&m.pendingCount, 1)
+	m.mu.Unlock()
+	return true, m.wake()
+}
+
+func (m *mailbox) wake() bool {
+	return atomic.CompareAndSwapInt32(&m.scheduled, 0, 1)
+}
+
+// take returns the next stashed message, command or message. It might return false while a message is being
+// pushed.
+func (m *mailbox) take() (interface{}, bool) {
+	if len(m.stash) > 0 {
+		env := m.stash[0]
+		m.stash = m.stash[1:]
+		return env, true
+	}
+
+	if atomic.LoadInt32(&m.pendingCount) > 0 {
+		m.mu.Lock()
+		cmd := m.commands[0]
+		m.commands[0] = nil
+		m.commands = m.commands[1:]
+		atomic.AddInt32(&m.pendingCount, -1)
+		m.mu.Unlock()
+		return cmd, true
+	}
+
+	var env envelope
+	if m.choose != nil {
+		for n := m.messages.pop(); n != nil; n = m.messages.pop() {
+			m.pending = append(m.pending, n.env)
+		}
+		if len(m.pending) == 0 {
+			return nil, false
+		}
+		env = m.takeChosen()
+	} else {
+		n := m.messages.pop()
+		if n == nil {
+			return nil, false
+		}
+		env = n.env
+	}
+	atomic.AddInt64(&m.count, -1)
+	if atomic.LoadInt32(&m.waiters) != 0 {
+		m.signalNotFull()
+	}
+	return env, true
+}
+
+func (m *mailbox) signalNotFull() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	atomic.StoreInt32(&m.waiters, 0)
+	if m.notFull != nil {
+		close(m.notFull)
+		m.notFull = nil
+	}
+}
+
+// takeChosen removes the pending message picked by choose. Only the oldest message of every sender is a candidate,
+// so messages from the same sender are still taken in order.
+func (m *mailbox) takeChosen() envelope {
+	var candidates []int
+	for i, env := range m.pending {
+		first := true
+		for _, j := range candidates {
+			if m.pending[j].sender == env.sender {
+				first = false
+				break
+			}
+		}
+		if first {
+			candidates = append(candidates, i)
+		}
+	}
+	i := candidates[m.choose(len(candidates))]
+	env := m.pending[i]
+	last := len(m.pending) - 1
+	copy(m.pending[i:], m.pending[i+1:])
+	m.pending[last] = envelope{}
+	m.pending = m.pending[:last]
+	return env
+}
+
+// idle marks the actor as not scheduled if the mailbox is empty. It returns false if the actor has to keep
+// running.
+func (m *mailbox) idle() bool {
+	if len(m.stash) > 0 {
+		return false
+	}
+	atomic.StoreInt32(&m.scheduled, 0)
+	if atomic.LoadInt64(&m.count) > 0 || atomic.LoadInt32(&m.pendingCount) > 0 {
+		// a sender that saw the actor running might not have scheduled it
+		return !m.wake()
+	}
+	return true
+}
+
+// close stops accepting new messages and returns the dropped ones.
+func (m *mailbox) close() []envelope {
+	atomic.StoreInt32(&m.closed, 1)
+	dropped := append(m.stash, m.pending...)
+	for n := m.messages.pop(); n != nil; n = m.messages.pop() {
+		dropped = append(dropped, n.env)
+	}
+	atomic.AddInt64(&m.count, -int64(len(dropped)-len(m.stash)))
+	m.pending = nil
+	m.stash = nil
+	m.signalNotFull()
+	return dropped
+}
+
+// terminate stops accepting commands and returns the ones that weren't taken.
+func (m *mailbox) terminate() []interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terminated = true
+	commands := m.commands
+	m.commands = nil
+	atomic.StoreInt32(&m.pendingCount, 0)
+	return commands
+}
+
+func (m *mailbox) unstashAll(buffer []envelope) {
+	m.stash = append(m.stash, buffer...)
+}
+
+func (m *mailbox) isClosed() bool {
+	return atomic.LoadInt32(&m.closed) != 0
+}
+
+type node struct {
+	next unsafe.Pointer
+	env  envelope
+}
+
+// mpscQueue is an intrusive multi-producer single-consumer queue. Producers never wait for each other or for
+// the consumer.
+type mpscQueue struct {
+	// head is the last pushed node, tail is the next node to pop
+	head unsafe.Pointer
+	tail *node
+	stub node
+}
+
+func (q *mpscQueue) init() {
+	q.head = unsafe.Pointer(&q.stub)
+	q.tail = &q.stub
+}
+
+func (q *mpscQueue) push(n *node) {
+	prev := (*node)(atomic.SwapPointer(&q.head, unsafe.Pointer(n)))
+	atomic.StorePointer(&prev.next, unsafe.Pointer(n))
+}
+
+// pop returns the next node or nil if the queue is empty or the next node isn't linked yet.
+func (q *mpscQueue) pop() *node {
+	tail := q.tail
+	next := (*node)(atomic.LoadPointer(&tail.next))
+	if tail == &q.stub {
+		if next == nil {
+			return nil
+		}
+		q.tail = next
+		tail = next
+		next = (*node)(atomic.LoadPointer(&tail.next))
+	}
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	if unsafe.Pointer(tail) != atomic.LoadPointer(&q.head) {
+		return nil
+	}
+	atomic.StorePointer(&q.stub.next, nil)
+	q.push(&q.stub)
+	next = (*node)(atomic.LoadPointer(&tail.next))
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package tractor
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mailboxSender int
+
+func (mailboxSender) Tell(ActorContext, interface{}) {}
+
+var _ = Describe("mailbox", func() {
+	It("keeps the order of every producer", func() {
+		const producers, messages = 8, 10000
+		m := newMailbox(0)
+		var wg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(sender mailboxSender) {
+				defer wg.Done()
+				for i := 0; i < messages; i++ {
+					m.push(envelope{sender: sender, msg: i}, true, nil)
+				}
+			}(mailboxSender(p))
+		}
+
+		next := make([]int, producers)
+		for received := 0; received < producers*messages; {
+			item, ok := m.take()
+			if !ok {
+				continue
+			}
+			env := item.(envelope)
+			sender := env.sender.(mailboxSender)
+			Expect(env.msg).To(Equal(next[sender]))
+			next[sender]++
+			received++
+		}
+		wg.Wait()
+		_, ok := m.take()
+		Expect(ok).To(BeFalse())
+		Expect(m.idle()).To(BeTrue())
+	})
+
+	It("blocks senders while full", func() {
+		m := newMailbox(2)
+		Expect(m.push(envelope{msg: 1}, true, nil)).To(BeTrue())
+		Expect(m.push(envelope{msg: 2}, true, nil)).To(BeTrue())
+		pushed := make(chan bool)
+		go func() {
+			ok, _ := m.push(envelope{msg: 3}, true, nil)
+			pushed <- ok
+		}()
+		Consistently(pushed).ShouldNot(Receive())
+		item, _ := m.take()
+		Expect(item).To(Equal(envelope{msg: 1}))
+		Eventually(pushed).Should(Receive(BeTrue()))
+
+		abort := make(chan struct{})
+		close(abort)
+		ok, _ := m.push(envelope{msg: 4}, true, abort)
+		Expect(ok).To(BeFalse())
+		m.close()
+		ok, _ = m.push(envelope{msg: 5}, true, nil)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("schedules an idle actor once", func() {
+		m := newMailbox(0)
+		Expect(m.idle()).To(BeTrue())
+		_, schedule := m.push(envelope{msg: 1}, true, nil)
+		Expect(schedule).To(BeTrue())
+		_, schedule = m.push(envelope{msg: 2}, true, nil)
+		Expect(schedule).To(BeFalse())
+		_, schedule = m.pushCommand(&terminateCommand{})
+		Expect(schedule).To(BeFalse())
+		Expect(m.idle()).To(BeFalse())
+	})
+})
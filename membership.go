@@ -0,0 +1,58 @@
+package tractor
+
+type joinMembership struct{}
+
+type subscribeMembers struct{}
+
+type memberTerminated struct {
+	ref ActorRef
+}
+
+// membersChanged lists current members ordered by age, the oldest first.
+type membersChanged struct {
+	members []ActorRef
+}
+
+// ClusterMembership tracks members that joined it ordered by age. Every actor that joins it plays the role of
+// a cluster node and leaves the cluster when it terminates.
+func ClusterMembership() SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		var members []ActorRef
+		var subscribers []ActorRef
+
+		publish := func() {
+			snapshot := append([]ActorRef(nil), members...)
+			for _, s := range subscribers {
+				s.Tell(ctx, membersChanged{members: snapshot})
+			}
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case joinMembership:
+				members = append(members, ctx.Sender())
+				subscribers = append(subscribers, ctx.Sender())
+				ctx.WatchWith(ctx.Sender(), memberTerminated{ref: ctx.Sender()})
+				publish()
+			case subscribeMembers:
+				subscribers = append(subscribers, ctx.Sender())
+				ctx.WatchWith(ctx.Sender(), memberTerminated{ref: ctx.Sender()})
+				ctx.Sender().Tell(ctx, membersChanged{members: append([]ActorRef(nil), members...)})
+			case memberTerminated:
+				members = removeRef(members, m.ref)
+				subscribers = removeRef(subscribers, m.ref)
+				publish()
+			}
+			return nil
+		}
+	}
+}
+
+func removeRef(refs []ActorRef, ref ActorRef) []ActorRef {
+	for i, r := range refs {
+		if r == ref {
+			return append(refs[:i], refs[i+1:]...)
+		}
+	}
+	return refs
+}
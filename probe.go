@@ -0,0 +1,178 @@
+package tractor
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TestingT is the part of testing.T used by TestProbe. GinkgoT() implements it as well.
+type TestingT interface {
+	Fatalf(format string, args ...interface{})
+}
+
+// TestProbe is a reference that can be used by tests to assert on the messages it receives. Expectations fail
+// the test instead of blocking when the expected message doesn't arrive in time.
+type TestProbe struct {
+	t          TestingT
+	ctx        ActorContext
+	mu         sync.Mutex
+	queue      []envelope
+	notify     chan struct{}
+	lastSender ActorRef
+}
+
+// NewTestProbe creates a probe that sends messages on behalf of itself in the system.
+func NewTestProbe(t TestingT, system ActorSystem) *TestProbe {
+	probe := &TestProbe{t: t, notify: make(chan struct{}, 1)}
+	probe.ctx = senderContext{ActorContext: system.Context(), sender: probe}
+	return probe
+}
+
+func (probe *TestProbe) Tell(ctx ActorContext, msg interface{}) {
+	probe.mu.Lock()
+	probe.queue = append(probe.queue, envelope{sender: ctx.Self(), msg: msg})
+	probe.mu.Unlock()
+	select {
+	case probe.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Send tells the message to the ref with the probe as the sender.
+func (probe *TestProbe) Send(ref ActorRef, msg interface{}) {
+	ref.Tell(probe.ctx, msg)
+}
+
+// Reply tells the message to the sender of the last received message.
+func (probe *TestProbe) Reply(msg interface{}) {
+	probe.Send(probe.lastSender, msg)
+}
+
+func (probe *TestProbe) LastSender() ActorRef {
+	return probe.lastSender
+}
+
+// Watch delivers Terminated{Ref: ref} to the probe when the actor terminates.
+func (probe *TestProbe) Watch(ref ActorRef) {
+	if local, ok := ref.(*localActorRef); ok {
+		if !local.context.tellCommand(&listenCommand{ref: probe, msg: Terminated{Ref: ref}}) {
+			probe.Tell(local.context, local.context.terminatedMessage(Terminated{Ref: ref}))
+		}
+	}
+}
+
+func (probe *TestProbe) receive(timeout time.Duration) (interface{}, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		probe.mu.Lock()
+		if len(probe.queue) > 0 {
+			env := probe.queue[0]
+			probe.queue = probe.queue[1:]
+			probe.mu.Unlock()
+			probe.lastSender = env.sender
+			return env.msg, true
+		}
+		probe.mu.Unlock()
+
+		select {
+		case <-probe.notify:
+		case <-timer.C:
+			return nil, false
+		}
+	}
+}
+
+func (probe *TestProbe) fail(format string, args ...interface{}) {
+	if h, ok := probe.t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	probe.t.Fatalf(format, args...)
+}
+
+// ReceiveMessage returns the next message.
+func (probe *TestProbe) ReceiveMessage(timeout time.Duration) interface{} {
+	msg, ok := probe.receive(timeout)
+	if !ok {
+		probe.fail("timeout (%v) while waiting for a message", timeout)
+	}
+	return msg
+}
+
+// ExpectMessage expects the next message to be equal to msg.
+func (probe *TestProbe) ExpectMessage(msg interface{}, timeout time.Duration) interface{} {
+	received, ok := probe.receive(timeout)
+	if !ok {
+		probe.fail("timeout (%v) while waiting for %#v", timeout, msg)
+	} else if !reflect.DeepEqual(received, msg) {
+		probe.fail("expected %#v, received %#v", msg, received)
+	}
+	return received
+}
+
+// ExpectMessageType expects the next message to be of type T.
+func ExpectMessageType[T any](probe *TestProbe, timeout time.Duration) T {
+	var result T
+	received, ok := probe.receive(timeout)
+	if !ok {
+		probe.fail("timeout (%v) while waiting for %T", timeout, result)
+		return result
+	}
+	result, ok = received.(T)
+	if !ok {
+		probe.fail("expected %T, received %#v", result, received)
+	}
+	return result
+}
+
+// ExpectNoMessage expects that no message arrives for the duration.
+func (probe *TestProbe) ExpectNoMessage(d time.Duration) {
+	if received, ok := probe.receive(d); ok {
+		probe.fail("expected no message, received %#v", received)
+	}
+}
+
+// ExpectTerminated expects the watched actor to terminate for any cause and returns its Terminated message.
+func (probe *TestProbe) ExpectTerminated(ref ActorRef, timeout time.Duration) Terminated {
+	received, ok := probe.receive(timeout)
+	if !ok {
+		probe.fail("timeout (%v) while waiting for termination of %v", timeout, ref)
+		return Terminated{}
+	}
+	terminated, ok := received.(Terminated)
+	if !ok || terminated.Ref != ref {
+		probe.fail("expected termination of %v, received %#v", ref, received)
+	}
+	return terminated
+}
+
+// FishForMessage skips messages until the predicate returns true for one of them, and returns it.
+func (probe *TestProbe) FishForMessage(timeout time.Duration, predicate func(msg interface{}) bool) interface{} {
+	deadline := time.Now().Add(timeout)
+	for {
+		received, ok := probe.receive(time.Until(deadline))
+		if !ok {
+			probe.fail("timeout (%v) while fishing for a message", timeout)
+			return nil
+		}
+		if predicate(received) {
+			return received
+		}
+	}
+}
+
+// ReceiveN returns the next n messages.
+func (probe *TestProbe) ReceiveN(n int, timeout time.Duration) []interface{} {
+	deadline := time.Now().Add(timeout)
+	var result []interface{}
+	for len(result) < n {
+		received, ok := probe.receive(time.Until(deadline))
+		if !ok {
+			probe.fail("timeout (%v) while waiting for %d messages, received %d", timeout, n, len(result))
+			return result
+		}
+		result = append(result, received)
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package tractor
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failures struct {
+	messages []string
+}
+
+func (f *failures) Fatalf(format string, args ...interface{}) {
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+func echo(ctx ActorContext) MessageHandler {
+	return func(msg interface{}) MessageHandler {
+		if msg == "stop" {
+			return Stopped()
+		}
+		ctx.Sender().Tell(ctx, msg)
+		return nil
+	}
+}
+
+var _ = Describe("TestProbe", func() {
+	var system ActorSystem
+	var probe *TestProbe
+
+	BeforeEach(func() {
+		system = Start(echo)
+		probe = NewTestProbe(GinkgoT(), system)
+	})
+
+	AfterEach(func() {
+		system.Root().Tell(system.Context(), "stop")
+		system.Wait()
+	})
+
+	It("expects messages", func() {
+		probe.Send(system.Root(), "hello")
+		Expect(probe.ExpectMessage("hello", time.Second)).To(Equal("hello"))
+		Expect(probe.LastSender()).To(Equal(system.Root()))
+
+		probe.Send(system.Root(), 42)
+		Expect(ExpectMessageType[int](probe, time.Second)).To(Equal(42))
+		probe.ExpectNoMessage(10 * time.Millisecond)
+	})
+
+	It("replies to the last sender", func() {
+		other := NewTestProbe(GinkgoT(), system)
+		other.Send(probe, "ping")
+		probe.ExpectMessage("ping", time.Second)
+		probe.Reply("pong")
+		other.ExpectMessage("pong", time.Second)
+	})
+
+	It("receives and fishes for messages", func() {
+		for i := 0; i < 5; i++ {
+			probe.Send(system.Root(), i)
+		}
+		Expect(probe.ReceiveN(2, time.Second)).To(Equal([]interface{}{0, 1}))
+		Expect(probe.FishForMessage(time.Second, func(msg interface{}) bool {
+			return msg.(int) > 3
+		})).To(Equal(4))
+		probe.ExpectNoMessage(10 * time.Millisecond)
+	})
+
+	It("expects termination", func() {
+		actor := system.Context().Spawn(echo)
+		probe.Watch(actor)
+		probe.Send(actor, "stop")
+		probe.ExpectTerminated(actor, time.Second)
+	})
+
+	It("expects termination of failed actors", func() {
+		actor := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				panic("failed")
+			}
+		})
+		probe.Watch(actor)
+		probe.Send(actor, "fail")
+		Expect(probe.ExpectTerminated(actor, time.Second).Cause.Reason).To(Equal(StopFailed))
+	})
+
+	It("fails instead of blocking", func() {
+		f := &failures{}
+		failing := NewTestProbe(f, system)
+		failing.ExpectMessage("hello", 10*time.Millisecond)
+		failing.Send(system.Root(), "bye")
+		failing.ExpectMessage("hello", time.Second)
+		failing.Send(system.Root(), "bye")
+		ExpectMessageType[int](failing, time.Second)
+		failing.ReceiveN(1, 10*time.Millisecond)
+		failing.Send(system.Root(), "bye")
+		failing.ExpectNoMessage(time.Second)
+		Expect(f.messages).To(Equal([]string{
+			`timeout (10ms) while waiting for "hello"`,
+			`expected "hello", received "bye"`,
+			`expected int, received "bye"`,
+			`timeout (10ms) while waiting for 1 messages, received 0`,
+			`expected no message, received "bye"`,
+		}))
+	})
+})
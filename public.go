@@ -1,9 +1,14 @@
 package tractor
 
+import "context"
+
 type ActorSystem interface {
 	Root() ActorRef
 	Context() ActorContext
 	Wait()
+	// Terminate runs the coordinated shutdown and returns when it finished or ctx is done.
+	Terminate(ctx context.Context) error
+	CoordinatedShutdown() *CoordinatedShutdown
 }
 
 type ActorRef interface {
@@ -16,32 +21,119 @@ type ActorContext interface {
 	Sender() ActorRef
 
 	Children() []ActorRef
-	Spawn(setup SetupHandler) ActorRef
+	Spawn(setup SetupHandler, options ...SpawnOption) ActorRef
+	// Stop stops a child or the actor itself.
+	Stop(ref ActorRef)
 	Watch(actor ActorRef)
 	WatchWith(actor ActorRef, msg interface{})
+	Unwatch(actor ActorRef)
 
 	DeliverSignals(value bool)
 	Ask(ref ActorRef, msg interface{}) chan interface{}
-	NewStash(size int) StashBuffer
+	// MessageAdapter returns a reference that converts told messages with adapt and delivers them to the actor
+	// with their original sender. Messages converted to nil are dropped.
+	MessageAdapter(adapt func(msg interface{}) interface{}) ActorRef
+	// NewStash creates a stash holding up to size messages, a non positive size is unbounded.
+	NewStash(size int, options ...StashOption) StashBuffer
+	EventStream() EventStream
+	CoordinatedShutdown() *CoordinatedShutdown
 }
 
 type PostInitSignal struct{}
+
+// PreRestartSignal is delivered to the failed handler of a supervised actor before it is restarted.
+type PreRestartSignal struct {
+	Cause TerminationCause
+}
+
+// PostRestartSignal is delivered to the handler returned by the setup handler after a restart instead of
+// PostInitSignal.
+type PostRestartSignal struct {
+	Cause TerminationCause
+}
+
 type PreStopSignal struct{}
-type PostStopSignal struct{}
+type PostStopSignal struct {
+	Cause TerminationCause
+}
 
+// Terminated is delivered to watchers. Watch fills in Ref and Cause, custom messages of WatchWith are delivered as
+// they are.
 type Terminated struct {
-	Ref ActorRef
+	Ref   ActorRef
+	Cause TerminationCause
+}
+
+// ChildFailed is a signal delivered to the parent when a child terminates because it panicked.
+type ChildFailed struct {
+	Ref   ActorRef
+	Cause TerminationCause
+}
+
+type StopReason int
+
+const (
+	// StopNormal is used when the actor stopped itself or was stopped with Stop or PoisonPill.
+	StopNormal StopReason = iota
+	// StopFailed is used when the actor panicked.
+	StopFailed
+	// StopParentStopped is used when the actor stopped because its parent did.
+	StopParentStopped
+	// StopSupervisor is used when the supervisor stopped the actor after a failure.
+	StopSupervisor
+	// StopSystemShutdown is used when the actor stopped because the system terminated.
+	StopSystemShutdown
+)
+
+// TerminationCause tells why an actor stopped. Panic and Stack are set for failures.
+type TerminationCause struct {
+	Reason StopReason
+	Panic  interface{}
+	Stack  []byte
+}
+
+// SpawnOption configures a spawned actor.
+type SpawnOption func(settings *spawnSettings)
+
+type spawnSettings struct {
+	dispatcher Dispatcher
+	batchSize  int
+}
+
+// OnDispatcher runs the spawned actor on the dispatcher instead of the one of the system.
+func OnDispatcher(dispatcher Dispatcher) SpawnOption {
+	return func(settings *spawnSettings) {
+		settings.dispatcher = dispatcher
+	}
 }
 
 type SetupHandler func(ctx ActorContext) MessageHandler
 type MessageHandler func(message interface{}) MessageHandler
 
 func Stopped() MessageHandler {
-	return stopped.handle
+	return stoppedHandler
+}
+
+// Unhandled is returned by a handler that doesn't handle the message. The current handler is kept and the message
+// is reported to the system.
+func Unhandled() MessageHandler {
+	return unhandledHandler
 }
 
+// StashBuffer holds messages to be processed later. Messages can be stashed while handling signals, they have no
+// sender then.
 type StashBuffer interface {
+	// Stash appends the message, applying the overflow strategy if the stash is full.
 	Stash(msg interface{})
+	// UnstashAll prepends all stashed messages to the mailbox and returns the handler.
 	UnstashAll(handler MessageHandler) MessageHandler
+	// Unstash prepends up to count oldest stashed messages to the mailbox and returns the handler.
 	Unstash(handler MessageHandler, count int) MessageHandler
+	Size() int
+	IsFull() bool
+	Foreach(f func(msg interface{}))
+	Exists(predicate func(msg interface{}) bool) bool
+	Clear()
+	// Head returns the oldest stashed message or nil if the stash is empty.
+	Head() interface{}
 }
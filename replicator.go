@@ -0,0 +1,290 @@
+package tractor
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+type ConsistencyLevel int
+
+const (
+	// ConsistencyLocal reads or writes the local replica only.
+	ConsistencyLocal ConsistencyLevel = iota
+	// ConsistencyMajority reads or writes a majority of replicas.
+	ConsistencyMajority
+	// ConsistencyAll reads or writes all replicas.
+	ConsistencyAll
+)
+
+type Consistency struct {
+	Level   ConsistencyLevel
+	Timeout time.Duration
+}
+
+type ReplicatorSettings struct {
+	GossipInterval time.Duration
+}
+
+const defaultGossipInterval = 2 * time.Second
+const defaultConsistencyTimeout = 3 * time.Second
+
+type ReplicatorGet struct {
+	Key         string
+	Consistency Consistency
+}
+
+type ReplicatorGetSuccess struct {
+	Key  string
+	Data ReplicatedData
+}
+
+type ReplicatorNotFound struct {
+	Key string
+}
+
+type ReplicatorGetFailure struct {
+	Key string
+}
+
+// ReplicatorUpdate applies Modify to the current value of the key, or to Initial if there is none.
+// Modify receives the id of the local node to be used with node-aware data types.
+type ReplicatorUpdate struct {
+	Key         string
+	Initial     ReplicatedData
+	Consistency Consistency
+	Modify      func(data ReplicatedData, node string) ReplicatedData
+}
+
+type ReplicatorUpdateSuccess struct {
+	Key string
+}
+
+type ReplicatorUpdateTimeout struct {
+	Key string
+}
+
+// ReplicatorSubscribe registers the sender to receive ReplicatorChanged notifications for the key.
+type ReplicatorSubscribe struct {
+	Key string
+}
+
+type ReplicatorChanged struct {
+	Key  string
+	Data ReplicatedData
+}
+
+type gossipTick struct{}
+
+type gossip struct {
+	entries map[string]ReplicatedData
+}
+
+type replicaWrite struct {
+	key  string
+	data ReplicatedData
+}
+
+type replicaWriteAck struct{}
+
+type replicaRead struct {
+	key string
+}
+
+type replicaReadResult struct {
+	data ReplicatedData
+}
+
+type aggregatorTimeout struct{}
+
+// subscriberTerminated clears all subscriptions of the ref, a ref is watched once however many keys it subscribed to.
+type subscriberTerminated struct {
+	ref ActorRef
+}
+
+// Replicator stores replicated data on the local node and replicates it to the replicators of the other
+// members of the membership.
+func Replicator(membership ActorRef, settings ReplicatorSettings) SetupHandler {
+	if settings.GossipInterval == 0 {
+		settings.GossipInterval = defaultGossipInterval
+	}
+
+	return func(ctx ActorContext) MessageHandler {
+		ctx.DeliverSignals(true)
+		membership.Tell(ctx, joinMembership{})
+
+		node := fmt.Sprintf("%p", ctx.Self())
+		entries := map[string]ReplicatedData{}
+		subscribers := map[string][]ActorRef{}
+		var peers []ActorRef
+		tick := scheduleOnce(ctx, settings.GossipInterval, gossipTick{})
+
+		write := func(key string, data ReplicatedData) {
+			existing, ok := entries[key]
+			if ok {
+				data = existing.Merge(data)
+				if reflect.DeepEqual(existing, data) {
+					return
+				}
+			}
+			entries[key] = data
+			for _, s := range subscribers[key] {
+				s.Tell(ctx, ReplicatorChanged{Key: key, Data: data})
+			}
+		}
+
+		required := func(level ConsistencyLevel) int {
+			switch level {
+			case ConsistencyMajority:
+				return (len(peers)+1)/2 + 1
+			case ConsistencyAll:
+				return len(peers) + 1
+			}
+			return 1
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case PostInitSignal, PreStopSignal:
+			case PostStopSignal:
+				tick.Stop()
+			case membersChanged:
+				peers = nil
+				for _, member := range m.members {
+					if member != ctx.Self() {
+						peers = append(peers, member)
+					}
+				}
+			case ReplicatorGet:
+				data, ok := entries[m.Key]
+				if n := required(m.Consistency.Level); n > 1 {
+					ctx.Spawn(readAggregator(m, data, peers, n-1, ctx.Sender(), ctx.Self()))
+				} else if ok {
+					ctx.Sender().Tell(ctx, ReplicatorGetSuccess{Key: m.Key, Data: data})
+				} else {
+					ctx.Sender().Tell(ctx, ReplicatorNotFound{Key: m.Key})
+				}
+			case ReplicatorUpdate:
+				data, ok := entries[m.Key]
+				if !ok {
+					data = m.Initial
+				}
+				write(m.Key, m.Modify(data, node))
+				if n := required(m.Consistency.Level); n > 1 {
+					ctx.Spawn(writeAggregator(m, entries[m.Key], peers, n-1, ctx.Sender()))
+				} else {
+					ctx.Sender().Tell(ctx, ReplicatorUpdateSuccess{Key: m.Key})
+				}
+			case ReplicatorSubscribe:
+				subscribers[m.Key] = append(subscribers[m.Key], ctx.Sender())
+				ctx.WatchWith(ctx.Sender(), subscriberTerminated{ref: ctx.Sender()})
+				if data, ok := entries[m.Key]; ok {
+					ctx.Sender().Tell(ctx, ReplicatorChanged{Key: m.Key, Data: data})
+				}
+			case subscriberTerminated:
+				for key, refs := range subscribers {
+					subscribers[key] = removeRef(refs, m.ref)
+				}
+			case replicaWrite:
+				write(m.key, m.data)
+				ctx.Sender().Tell(ctx, replicaWriteAck{})
+			case replicaRead:
+				ctx.Sender().Tell(ctx, replicaReadResult{data: entries[m.key]})
+			case gossip:
+				for key, data := range m.entries {
+					write(key, data)
+				}
+			case gossipTick:
+				if len(peers) > 0 {
+					snapshot := make(map[string]ReplicatedData, len(entries))
+					for key, data := range entries {
+						snapshot[key] = data
+					}
+					peers[rand.Intn(len(peers))].Tell(ctx, gossip{entries: snapshot})
+				}
+				tick = scheduleOnce(ctx, settings.GossipInterval, gossipTick{})
+			}
+			return nil
+		}
+	}
+}
+
+func consistencyTimeout(c Consistency) time.Duration {
+	if c.Timeout == 0 {
+		return defaultConsistencyTimeout
+	}
+	return c.Timeout
+}
+
+func writeAggregator(update ReplicatorUpdate, data ReplicatedData, peers []ActorRef, acks int, replyTo ActorRef) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		if acks > len(peers) {
+			replyTo.Tell(ctx, ReplicatorUpdateTimeout{Key: update.Key})
+			return Stopped()
+		}
+		for _, peer := range peers {
+			peer.Tell(ctx, replicaWrite{key: update.Key, data: data})
+		}
+		timer := scheduleOnce(ctx, consistencyTimeout(update.Consistency), aggregatorTimeout{})
+
+		return func(msg interface{}) MessageHandler {
+			switch msg.(type) {
+			case replicaWriteAck:
+				acks--
+				if acks == 0 {
+					timer.Stop()
+					replyTo.Tell(ctx, ReplicatorUpdateSuccess{Key: update.Key})
+					return Stopped()
+				}
+			case aggregatorTimeout:
+				replyTo.Tell(ctx, ReplicatorUpdateTimeout{Key: update.Key})
+				return Stopped()
+			}
+			return nil
+		}
+	}
+}
+
+func readAggregator(get ReplicatorGet, data ReplicatedData, peers []ActorRef, results int, replyTo ActorRef, replicator ActorRef) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		if results > len(peers) {
+			replyTo.Tell(ctx, ReplicatorGetFailure{Key: get.Key})
+			return Stopped()
+		}
+		for _, peer := range peers {
+			peer.Tell(ctx, replicaRead{key: get.Key})
+		}
+		timer := scheduleOnce(ctx, consistencyTimeout(get.Consistency), aggregatorTimeout{})
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case replicaReadResult:
+				if m.data != nil {
+					if data == nil {
+						data = m.data
+					} else {
+						data = data.Merge(m.data)
+					}
+				}
+				results--
+				if results > 0 {
+					return nil
+				}
+				timer.Stop()
+				if data == nil {
+					replyTo.Tell(ctx, ReplicatorNotFound{Key: get.Key})
+				} else {
+					// repair the local replica with the merged value
+					replicator.Tell(ctx, replicaWrite{key: get.Key, data: data})
+					replyTo.Tell(ctx, ReplicatorGetSuccess{Key: get.Key, Data: data})
+				}
+				return Stopped()
+			case aggregatorTimeout:
+				replyTo.Tell(ctx, ReplicatorGetFailure{Key: get.Key})
+				return Stopped()
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package tractor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func increment(data ReplicatedData, node string) ReplicatedData {
+	return data.(GCounter).Increment(node, 1)
+}
+
+// awaitMembers waits until n members joined the membership. Members are notified before the membership replies.
+func awaitMembers(ctx ActorContext, membership ActorRef, n int) {
+	Eventually(func() int {
+		return len((<-ctx.Ask(membership, subscribeMembers{})).(membersChanged).members)
+	}).Should(Equal(n))
+}
+
+var _ = Describe("Replicator", func() {
+	It("reads local writes", func() {
+		root := func(ctx ActorContext) MessageHandler {
+			replicator := ctx.Spawn(Replicator(ctx.Spawn(ClusterMembership()), ReplicatorSettings{}))
+
+			Expect(<-ctx.Ask(replicator, ReplicatorGet{Key: "counter"})).To(Equal(ReplicatorNotFound{Key: "counter"}))
+			Expect(<-ctx.Ask(replicator, ReplicatorUpdate{Key: "counter", Initial: NewGCounter(), Modify: increment})).To(Equal(ReplicatorUpdateSuccess{Key: "counter"}))
+			reply := <-ctx.Ask(replicator, ReplicatorGet{Key: "counter"})
+			Expect(reply.(ReplicatorGetSuccess).Data.(GCounter).Value()).To(Equal(uint64(1)))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("writes to all replicas", func() {
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			a := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: time.Hour}))
+			b := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: time.Hour}))
+			awaitMembers(ctx, membership, 2)
+
+			all := Consistency{Level: ConsistencyAll, Timeout: time.Second}
+			Expect(<-ctx.Ask(a, ReplicatorUpdate{Key: "counter", Initial: NewGCounter(), Consistency: all, Modify: increment})).To(Equal(ReplicatorUpdateSuccess{Key: "counter"}))
+			Expect(<-ctx.Ask(b, ReplicatorUpdate{Key: "counter", Initial: NewGCounter(), Consistency: all, Modify: increment})).To(Equal(ReplicatorUpdateSuccess{Key: "counter"}))
+
+			for _, r := range []ActorRef{a, b} {
+				reply := <-ctx.Ask(r, ReplicatorGet{Key: "counter"})
+				Expect(reply.(ReplicatorGetSuccess).Data.(GCounter).Value()).To(Equal(uint64(2)))
+			}
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("reads from a majority of replicas", func() {
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			a := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: time.Hour}))
+			b := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: time.Hour}))
+			awaitMembers(ctx, membership, 2)
+
+			Expect(<-ctx.Ask(a, ReplicatorUpdate{Key: "flag", Initial: NewFlag(), Modify: func(data ReplicatedData, _ string) ReplicatedData {
+				return data.(Flag).SwitchOn()
+			}})).To(Equal(ReplicatorUpdateSuccess{Key: "flag"}))
+
+			Expect(<-ctx.Ask(b, ReplicatorGet{Key: "flag"})).To(Equal(ReplicatorNotFound{Key: "flag"}))
+			reply := <-ctx.Ask(b, ReplicatorGet{Key: "flag", Consistency: Consistency{Level: ConsistencyMajority, Timeout: time.Second}})
+			Expect(reply.(ReplicatorGetSuccess).Data.(Flag).Enabled()).To(BeTrue())
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("treats the only replica as all replicas", func() {
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			replicator := ctx.Spawn(Replicator(membership, ReplicatorSettings{}))
+			awaitMembers(ctx, membership, 1)
+
+			all := Consistency{Level: ConsistencyAll, Timeout: 10 * time.Millisecond}
+			Expect(<-ctx.Ask(replicator, ReplicatorUpdate{Key: "counter", Initial: NewGCounter(), Consistency: all, Modify: increment})).To(Equal(ReplicatorUpdateSuccess{Key: "counter"}))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("gossips changes to subscribers", func() {
+		changed := make(chan interface{}, 10)
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			a := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: 10 * time.Millisecond}))
+			b := ctx.Spawn(Replicator(membership, ReplicatorSettings{GossipInterval: 10 * time.Millisecond}))
+			awaitMembers(ctx, membership, 2)
+
+			b.Tell(ctx, ReplicatorSubscribe{Key: "set"})
+			a.Tell(ctx, ReplicatorUpdate{Key: "set", Initial: NewORSet(), Modify: func(data ReplicatedData, node string) ReplicatedData {
+				return data.(ORSet).Add(node, "x")
+			}})
+			return func(msg interface{}) MessageHandler {
+				if c, ok := msg.(ReplicatorChanged); ok {
+					changed <- c
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		c := (<-changed).(ReplicatorChanged)
+		Expect(c.Data.(ORSet).Contains("x")).To(BeTrue())
+	})
+
+	It("clears all subscriptions of a terminated subscriber", func() {
+		changed := make(chan interface{}, 10)
+		root := func(ctx ActorContext) MessageHandler {
+			events, deadLetters := ToChannel(ctx, 10)
+			ctx.EventStream().Subscribe(events, DeadLetter{})
+			replicator := ctx.Spawn(Replicator(ctx.Spawn(ClusterMembership()), ReplicatorSettings{}))
+			subscriber := ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				replicator.Tell(ctx, ReplicatorSubscribe{Key: "a"})
+				replicator.Tell(ctx, ReplicatorSubscribe{Key: "b"})
+				return func(msg interface{}) MessageHandler {
+					changed <- msg
+					return nil
+				}
+			})
+			update := func(key string) {
+				Expect(<-ctx.Ask(replicator, ReplicatorUpdate{Key: key, Initial: NewGCounter(), Modify: increment})).To(Equal(ReplicatorUpdateSuccess{Key: key}))
+			}
+			Eventually(func() int {
+				update("a")
+				update("b")
+				return len(changed)
+			}).Should(BeNumerically(">=", 2))
+
+			ctx.Watch(subscriber)
+			subscriber.Tell(ctx, PoisonPill{})
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					update("a")
+					update("b")
+					<-ctx.Ask(replicator, ReplicatorGet{Key: "a"})
+					Expect(deadLetters).NotTo(Receive())
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+})
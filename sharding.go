@@ -0,0 +1,389 @@
+package tractor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ShardingEnvelope addresses a message to the entity with the given id.
+type ShardingEnvelope struct {
+	EntityID string
+	Message  interface{}
+}
+
+// MessageExtractor maps messages sent to a shard region onto entities and shards.
+type MessageExtractor interface {
+	EntityID(msg interface{}) string
+	ShardID(entityID string) string
+	EntityMessage(msg interface{}) interface{}
+}
+
+// Passivate is sent by an entity to its parent to request a graceful stop.
+type Passivate struct{}
+
+type EntityFactory func(entityID string) SetupHandler
+
+type ShardingSettings struct {
+	// StopMessage is sent to entities that are passivated or handed off.
+	// Entities are expected to return Stopped() when they receive it.
+	StopMessage interface{}
+	// PassivateIdleAfter passivates entities that haven't received a message for the duration. Zero disables it.
+	PassivateIdleAfter time.Duration
+}
+
+type hashMessageExtractor struct {
+	numberOfShards int
+}
+
+// NewHashMessageExtractor creates an extractor for ShardingEnvelope messages that distributes entities
+// over numberOfShards shards by hash of the entity id.
+func NewHashMessageExtractor(numberOfShards int) MessageExtractor {
+	return &hashMessageExtractor{numberOfShards: numberOfShards}
+}
+
+func (e *hashMessageExtractor) EntityID(msg interface{}) string {
+	if env, ok := msg.(ShardingEnvelope); ok {
+		return env.EntityID
+	}
+	return ""
+}
+
+func (e *hashMessageExtractor) ShardID(entityID string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(entityID))
+	return strconv.Itoa(int(h.Sum32() % uint32(e.numberOfShards)))
+}
+
+func (e *hashMessageExtractor) EntityMessage(msg interface{}) interface{} {
+	if env, ok := msg.(ShardingEnvelope); ok {
+		return env.Message
+	}
+	return msg
+}
+
+type registerRegion struct{}
+
+type regionTerminated struct {
+	ref ActorRef
+}
+
+type getShardHome struct {
+	shard string
+}
+
+type shardHome struct {
+	shard  string
+	region ActorRef
+}
+
+type regionLeft struct {
+	region ActorRef
+}
+
+type handOffShard struct {
+	shard string
+}
+
+type shardStopped struct {
+	shard string
+}
+
+// ShardCoordinator allocates shards to the regions registered with it and rebalances them
+// when regions join or leave. Each region plays the role of a node.
+func ShardCoordinator() SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		var regions []ActorRef
+		homes := map[string]ActorRef{}
+		// rebalancing maps shards being handed off to the region handing them off
+		rebalancing := map[string]ActorRef{}
+
+		shardsOf := func(region ActorRef) []string {
+			var shards []string
+			for shard, home := range homes {
+				if home == region {
+					shards = append(shards, shard)
+				}
+			}
+			sort.Strings(shards)
+			return shards
+		}
+
+		leastLoaded := func() ActorRef {
+			var result ActorRef
+			count := 0
+			for _, region := range regions {
+				if n := len(shardsOf(region)); result == nil || n < count {
+					result = region
+					count = n
+				}
+			}
+			return result
+		}
+
+		allocate := func(shard string) {
+			region := leastLoaded()
+			homes[shard] = region
+			for _, r := range regions {
+				r.Tell(ctx, shardHome{shard: shard, region: region})
+			}
+		}
+
+		rebalance := func(newRegion ActorRef) {
+			total := len(homes) + len(rebalancing)
+			target := (total + len(regions) - 1) / len(regions)
+			for _, region := range regions {
+				if region == newRegion {
+					continue
+				}
+				shards := shardsOf(region)
+				for i := 0; i < len(shards)-target; i++ {
+					delete(homes, shards[i])
+					rebalancing[shards[i]] = region
+					region.Tell(ctx, handOffShard{shard: shards[i]})
+				}
+			}
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case registerRegion:
+				regions = append(regions, ctx.Sender())
+				ctx.WatchWith(ctx.Sender(), regionTerminated{ref: ctx.Sender()})
+				for shard, home := range homes {
+					ctx.Sender().Tell(ctx, shardHome{shard: shard, region: home})
+				}
+				rebalance(ctx.Sender())
+			case regionTerminated:
+				regions = removeRef(regions, m.ref)
+				for _, shard := range shardsOf(m.ref) {
+					delete(homes, shard)
+				}
+				for _, r := range regions {
+					r.Tell(ctx, regionLeft{region: m.ref})
+				}
+				// the region won't report shards it was handing off as stopped
+				var abandoned []string
+				for shard, region := range rebalancing {
+					if region == m.ref {
+						abandoned = append(abandoned, shard)
+					}
+				}
+				sort.Strings(abandoned)
+				for _, shard := range abandoned {
+					delete(rebalancing, shard)
+					if len(regions) > 0 {
+						allocate(shard)
+					}
+				}
+			case getShardHome:
+				if home, ok := homes[m.shard]; ok {
+					ctx.Sender().Tell(ctx, shardHome{shard: m.shard, region: home})
+				} else if _, ok := rebalancing[m.shard]; !ok && len(regions) > 0 {
+					allocate(m.shard)
+				}
+			case shardStopped:
+				delete(rebalancing, m.shard)
+				if len(regions) > 0 {
+					allocate(m.shard)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+type shardTerminated struct {
+	shard string
+}
+
+// ShardRegion routes messages to entities. Shards hosted by this region are spawned as its children
+// and messages for shards hosted by other regions are forwarded to them.
+func ShardRegion(coordinator ActorRef, entity EntityFactory, extractor MessageExtractor, settings ShardingSettings) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		coordinator.Tell(ctx, registerRegion{})
+
+		homes := map[string]ActorRef{}
+		shards := map[string]ActorRef{}
+		handingOff := map[string]bool{}
+		buffers := map[string][]envelope{}
+
+		deliver := func(shard string, env envelope) {
+			home, ok := homes[shard]
+			if !ok || handingOff[shard] {
+				if _, requested := buffers[shard]; !requested && !handingOff[shard] {
+					coordinator.Tell(ctx, getShardHome{shard: shard})
+				}
+				buffers[shard] = append(buffers[shard], env)
+				return
+			}
+			if home != ctx.Self() {
+				forwardFrom(ctx, env.sender, home, env.msg)
+				return
+			}
+			ref, ok := shards[shard]
+			if !ok {
+				ref = ctx.Spawn(newShard(shard, entity, extractor, settings))
+				ctx.WatchWith(ref, shardTerminated{shard: shard})
+				shards[shard] = ref
+			}
+			forwardFrom(ctx, env.sender, ref, env.msg)
+		}
+
+		flush := func(shard string) {
+			buffer := buffers[shard]
+			delete(buffers, shard)
+			for _, env := range buffer {
+				deliver(shard, env)
+			}
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case shardHome:
+				homes[m.shard] = m.region
+				delete(handingOff, m.shard)
+				flush(m.shard)
+			case regionLeft:
+				for shard, home := range homes {
+					if home == m.region {
+						delete(homes, shard)
+						if _, ok := buffers[shard]; ok {
+							coordinator.Tell(ctx, getShardHome{shard: shard})
+						}
+					}
+				}
+			case handOffShard:
+				handingOff[m.shard] = true
+				if ref, ok := shards[m.shard]; ok {
+					ref.Tell(ctx, m)
+				} else {
+					coordinator.Tell(ctx, shardStopped{shard: m.shard})
+				}
+			case shardTerminated:
+				delete(shards, m.shard)
+				if handingOff[m.shard] {
+					delete(homes, m.shard)
+					coordinator.Tell(ctx, shardStopped{shard: m.shard})
+				}
+			default:
+				entityID := extractor.EntityID(msg)
+				if entityID == "" {
+					_, _ = fmt.Fprintf(os.Stderr, "shard region: can't extract entity id from %T\n", msg)
+					return nil
+				}
+				deliver(extractor.ShardID(entityID), envelope{sender: ctx.Sender(), msg: msg})
+			}
+			return nil
+		}
+	}
+}
+
+type entityTerminated struct {
+	entityID string
+}
+
+type passivateIdleTick struct{}
+
+type shardEntity struct {
+	ref          ActorRef
+	lastActivity time.Time
+	passivating  bool
+	buffer       []envelope
+}
+
+func newShard(shardID string, factory EntityFactory, extractor MessageExtractor, settings ShardingSettings) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		ctx.DeliverSignals(true)
+
+		entities := map[string]*shardEntity{}
+		handingOff := false
+		var tick Cancellable
+		if settings.PassivateIdleAfter > 0 {
+			tick = scheduleOnce(ctx, settings.PassivateIdleAfter/2, passivateIdleTick{})
+		}
+
+		passivate := func(entityID string, e *shardEntity) {
+			if !e.passivating {
+				e.passivating = true
+				e.ref.Tell(ctx, settings.StopMessage)
+			}
+		}
+
+		deliver := func(entityID string, env envelope) {
+			e, ok := entities[entityID]
+			if !ok {
+				ref := ctx.Spawn(factory(entityID))
+				ctx.WatchWith(ref, entityTerminated{entityID: entityID})
+				e = &shardEntity{ref: ref}
+				entities[entityID] = e
+			}
+			if e.passivating {
+				e.buffer = append(e.buffer, env)
+				return
+			}
+			e.lastActivity = now(ctx)
+			forwardFrom(ctx, env.sender, e.ref, extractor.EntityMessage(env.msg))
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case PostInitSignal, PreStopSignal:
+			case PostStopSignal:
+				if tick != nil {
+					tick.Stop()
+				}
+			case Passivate:
+				for id, e := range entities {
+					if e.ref == ctx.Sender() {
+						passivate(id, e)
+					}
+				}
+			case passivateIdleTick:
+				for id, e := range entities {
+					if now(ctx).Sub(e.lastActivity) >= settings.PassivateIdleAfter {
+						passivate(id, e)
+					}
+				}
+				tick = scheduleOnce(ctx, settings.PassivateIdleAfter/2, passivateIdleTick{})
+			case handOffShard:
+				handingOff = true
+				for id, e := range entities {
+					passivate(id, e)
+				}
+				if len(entities) == 0 {
+					return Stopped()
+				}
+			case entityTerminated:
+				e, ok := entities[m.entityID]
+				if !ok {
+					return nil
+				}
+				delete(entities, m.entityID)
+				if handingOff {
+					for _, env := range e.buffer {
+						forwardFrom(ctx, env.sender, ctx.Parent(), env.msg)
+					}
+					if len(entities) == 0 {
+						return Stopped()
+					}
+					return nil
+				}
+				for _, env := range e.buffer {
+					deliver(m.entityID, env)
+				}
+			default:
+				if handingOff {
+					// the region buffers messages until the shard is allocated again
+					forward(ctx, ctx.Parent(), msg)
+					return nil
+				}
+				deliver(extractor.EntityID(msg), envelope{sender: ctx.Sender(), msg: msg})
+			}
+			return nil
+		}
+	}
+}
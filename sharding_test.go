@@ -0,0 +1,223 @@
+package tractor
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type entityGet struct{}
+type entityStop struct{}
+
+func countingEntity(spawned *int32) EntityFactory {
+	return func(entityID string) SetupHandler {
+		return func(ctx ActorContext) MessageHandler {
+			atomic.AddInt32(spawned, 1)
+			count := 0
+			return func(msg interface{}) MessageHandler {
+				switch msg.(type) {
+				case entityGet:
+					count++
+					ctx.Sender().Tell(ctx, entityID+":"+string(rune('0'+count)))
+				case entityStop:
+					return Stopped()
+				case string:
+					ctx.Parent().Tell(ctx, Passivate{})
+					ctx.Sender().Tell(ctx, msg)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// countingStops counts the stop messages received by entities of the factory.
+func countingStops(stopped *int32, factory EntityFactory) EntityFactory {
+	return func(entityID string) SetupHandler {
+		setup := factory(entityID)
+		return func(ctx ActorContext) MessageHandler {
+			return Intercept(setup(ctx), func(msg interface{}, target MessageHandler) MessageHandler {
+				if _, ok := msg.(entityStop); ok {
+					atomic.AddInt32(stopped, 1)
+				}
+				return target(msg)
+			})
+		}
+	}
+}
+
+// awaitCoordinator returns when the coordinator processed the messages queued before the call. The first request
+// allocates a shard nobody uses, the coordinator replies to the second one. The actor must ignore shardHome.
+func awaitCoordinator(ctx ActorContext, coordinator ActorRef) {
+	coordinator.Tell(ctx, getShardHome{shard: "sync"})
+	<-ctx.Ask(coordinator, getShardHome{shard: "sync"})
+}
+
+// regionIn spawns an actor hosting a shard region, the region stops when the actor receives any message.
+func regionIn(ctx ActorContext, region SetupHandler) (parent ActorRef, ref ActorRef) {
+	refs := make(chan ActorRef, 1)
+	parent = ctx.Spawn(func(ctx ActorContext) MessageHandler {
+		refs <- ctx.Spawn(region)
+		return func(msg interface{}) MessageHandler {
+			return Stopped()
+		}
+	})
+	return parent, <-refs
+}
+
+var _ = Describe("Sharding", func() {
+	settings := ShardingSettings{StopMessage: entityStop{}}
+
+	It("routes messages to entities spawned on demand", func() {
+		var spawned int32
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			region := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), NewHashMessageExtractor(10), settings))
+
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "b", Message: entityGet{}})).To(Equal("b:1"))
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:2"))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(spawned).To(Equal(int32(2)))
+	})
+
+	It("respawns passivated entities", func() {
+		var spawned int32
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			region := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), NewHashMessageExtractor(10), settings))
+
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+			<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: "passivate"})
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(spawned).To(Equal(int32(2)))
+	})
+
+	It("passivates idle entities", func() {
+		var spawned, stopped int32
+		idleSettings := settings
+		idleSettings.PassivateIdleAfter = 20 * time.Millisecond
+		clock := NewManualClock(time.Now())
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			entity := countingStops(&stopped, countingEntity(&spawned))
+			region := ctx.Spawn(ShardRegion(coordinator, entity, NewHashMessageExtractor(10), idleSettings))
+			// the shard checks for idle entities every 10ms
+			tick := func() {
+				Eventually(clock.PendingTimers).Should(Equal(1))
+				clock.Advance(10 * time.Millisecond)
+			}
+
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+			tick()
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:2"))
+			tick()
+			Expect(atomic.LoadInt32(&stopped)).To(Equal(int32(0)))
+			tick()
+			Eventually(func() int32 { return atomic.LoadInt32(&stopped) }).Should(Equal(int32(1)))
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+			return Stopped()
+		}
+
+		system := Start(root, WithClock(clock))
+		system.Wait()
+		Expect(spawned).To(Equal(int32(2)))
+	})
+
+	It("rebalances shards when a region joins", func() {
+		var spawned int32
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			extractor := NewHashMessageExtractor(4)
+			first := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), extractor, settings))
+
+			ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+			for _, id := range ids {
+				Expect(<-ctx.Ask(first, ShardingEnvelope{EntityID: id, Message: entityGet{}})).To(Equal(id + ":1"))
+			}
+
+			second := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), extractor, settings))
+			for _, id := range ids {
+				Expect(<-ctx.Ask(second, ShardingEnvelope{EntityID: id, Message: entityGet{}})).To(MatchRegexp(id + ":[12]"))
+			}
+			Expect(atomic.LoadInt32(&spawned)).To(BeNumerically(">", int32(len(ids))))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("reallocates shards when a region leaves", func() {
+		var spawned int32
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			extractor := NewHashMessageExtractor(4)
+			leaving, region := regionIn(ctx, ShardRegion(coordinator, countingEntity(&spawned), extractor, settings))
+			// the region registered before the coordinator allocated the shard of the reply
+			Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: "b", Message: entityGet{}})).To(Equal("b:1"))
+			staying := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), extractor, settings))
+			Expect(<-ctx.Ask(staying, ShardingEnvelope{EntityID: "a", Message: entityGet{}})).To(Equal("a:1"))
+
+			ctx.Watch(leaving)
+			leaving.Tell(ctx, "stop")
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					// the region terminated before its parent, so the coordinator notified the remaining region
+					awaitCoordinator(ctx, coordinator)
+					for _, id := range []string{"a", "b", "c", "d"} {
+						Expect(<-ctx.Ask(staying, ShardingEnvelope{EntityID: id, Message: entityGet{}})).To(MatchRegexp(id + ":[12]"))
+					}
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("reallocates shards of a region that left while handing them off", func() {
+		var spawned int32
+		// entities ignore the stop message, so the hand off never completes
+		stuck := ShardingSettings{StopMessage: "ignored"}
+		root := func(ctx ActorContext) MessageHandler {
+			coordinator := ctx.Spawn(ShardCoordinator())
+			extractor := NewHashMessageExtractor(4)
+			ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+			leaving, region := regionIn(ctx, ShardRegion(coordinator, countingEntity(&spawned), extractor, stuck))
+			for _, id := range ids {
+				Expect(<-ctx.Ask(region, ShardingEnvelope{EntityID: id, Message: entityGet{}})).To(Equal(id + ":1"))
+			}
+			staying := ctx.Spawn(ShardRegion(coordinator, countingEntity(&spawned), extractor, stuck))
+
+			ctx.Watch(leaving)
+			leaving.Tell(ctx, "stop")
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					awaitCoordinator(ctx, coordinator)
+					for _, id := range ids {
+						Expect(<-ctx.Ask(staying, ShardingEnvelope{EntityID: id, Message: entityGet{}})).To(Equal(id + ":1"))
+					}
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+})
@@ -0,0 +1,194 @@
+package tractor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Phases of the coordinated shutdown in the order they run.
+const (
+	PhaseStopAccepting    = "stop-accepting"
+	PhaseDrain            = "drain"
+	PhaseUnbind           = "unbind"
+	PhaseActors           = "actors"
+	PhasePersistenceFlush = "persistence-flush"
+	PhaseClusterLeave     = "cluster-leave"
+)
+
+var shutdownPhases = []string{
+	PhaseStopAccepting, PhaseDrain, PhaseUnbind, PhaseActors, PhasePersistenceFlush, PhaseClusterLeave,
+}
+
+const defaultPhaseTimeout = 5 * time.Second
+
+// ShutdownTask is run during its phase. The context is cancelled when the phase times out.
+type ShutdownTask func(ctx context.Context) error
+
+// CoordinatedShutdown runs registered tasks phase by phase when the system is terminated with Terminate. Tasks of
+// a phase run concurrently, and the next phase starts when all of them finished or the phase timed out. The actors
+// are stopped in PhaseActors.
+type CoordinatedShutdown struct {
+	system *actorSystemImpl
+
+	mu       sync.Mutex
+	tasks    map[string][]namedTask
+	timeouts map[string]time.Duration
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+type namedTask struct {
+	name string
+	task ShutdownTask
+}
+
+func newCoordinatedShutdown(system *actorSystemImpl) *CoordinatedShutdown {
+	return &CoordinatedShutdown{
+		system:   system,
+		tasks:    map[string][]namedTask{},
+		timeouts: map[string]time.Duration{},
+		done:     make(chan struct{}),
+	}
+}
+
+// AddTask registers the task in the phase. Tasks added after the phase started are ignored.
+func (s *CoordinatedShutdown) AddTask(phase string, name string, task ShutdownTask) {
+	checkPhase(phase)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[phase] = append(s.tasks[phase], namedTask{name: name, task: task})
+}
+
+// SetPhaseTimeout changes the timeout of the phase, 5 seconds by default.
+func (s *CoordinatedShutdown) SetPhaseTimeout(phase string, timeout time.Duration) {
+	checkPhase(phase)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeouts[phase] = timeout
+}
+
+func checkPhase(phase string) {
+	for _, p := range shutdownPhases {
+		if p == phase {
+			return
+		}
+	}
+	panic(fmt.Sprintf("unknown shutdown phase: %s", phase))
+}
+
+// Run runs all phases once. Concurrent and later calls wait for the first one and return its result: the first
+// error of a task, or the error of ctx if it is done before all phases finished.
+func (s *CoordinatedShutdown) Run(ctx context.Context) error {
+	s.once.Do(func() {
+		go func() {
+			s.err = s.run(ctx)
+			close(s.done)
+		}()
+	})
+	select {
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *CoordinatedShutdown) run(ctx context.Context) error {
+	var firstErr error
+	for _, phase := range shutdownPhases {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		tasks := append([]namedTask(nil), s.tasks[phase]...)
+		timeout, ok := s.timeouts[phase]
+		s.mu.Unlock()
+		if !ok {
+			timeout = defaultPhaseTimeout
+		}
+		if phase == PhaseActors && s.system != nil {
+			tasks = append(tasks, namedTask{name: "stop actors", task: s.system.stopActors})
+		}
+		if err := runPhase(ctx, phase, timeout, tasks); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func runPhase(ctx context.Context, phase string, timeout time.Duration, tasks []namedTask) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errs := make(chan error, len(tasks))
+	for _, t := range tasks {
+		go func(t namedTask) {
+			if err := t.task(ctx); err != nil {
+				errs <- fmt.Errorf("shutdown phase %s, task %s: %w", phase, t.name, err)
+				return
+			}
+			errs <- nil
+		}(t)
+	}
+
+	var firstErr error
+	for range tasks {
+		select {
+		case err := <-errs:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case <-ctx.Done():
+			if firstErr == nil {
+				firstErr = fmt.Errorf("shutdown phase %s: %w", phase, ctx.Err())
+			}
+			return firstErr
+		}
+	}
+	return firstErr
+}
+
+// stopActors stops the root actor and other actors spawned by the system context, and waits until all actors
+// terminated.
+func (system *actorSystemImpl) stopActors(ctx context.Context) error {
+	for _, child := range system.context.Children() {
+		child.(*localActorRef).context.tellCommand(&terminateCommand{reason: StopSystemShutdown})
+	}
+	if d, ok := system.dispatcher.(*DeterministicDispatcher); ok {
+		d.runUntil(system.terminated)
+		return nil
+	}
+	select {
+	case <-system.terminated:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleSignals terminates the system with the coordinated shutdown on SIGTERM or SIGINT.
+func HandleSignals() SystemOption {
+	return func(system *actorSystemImpl) {
+		system.handleSignals = true
+	}
+}
+
+func (system *actorSystemImpl) watchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			_ = system.Terminate(context.Background())
+		case <-system.terminated:
+		}
+	}()
+}
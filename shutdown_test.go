@@ -0,0 +1,104 @@
+package tractor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coordinated shutdown", func() {
+	var system ActorSystem
+	var mu sync.Mutex
+	var log []string
+
+	record := func(entry string) {
+		mu.Lock()
+		defer mu.Unlock()
+		log = append(log, entry)
+	}
+
+	BeforeEach(func() {
+		log = nil
+		system = Start(func(ctx ActorContext) MessageHandler {
+			ctx.DeliverSignals(true)
+			return func(msg interface{}) MessageHandler {
+				switch msg.(type) {
+				case PostStopSignal:
+					record("root stopped")
+				case string:
+					ctx.Sender().Tell(ctx, msg)
+				}
+				return nil
+			}
+		})
+	})
+
+	It("runs the phases in order stopping the actors", func() {
+		shutdown := system.CoordinatedShutdown()
+		for _, phase := range []string{PhaseClusterLeave, PhasePersistenceFlush, PhaseUnbind, PhaseStopAccepting} {
+			phase := phase
+			shutdown.AddTask(phase, phase, func(ctx context.Context) error {
+				record(phase)
+				return nil
+			})
+		}
+		shutdown.AddTask(PhaseDrain, "drain", func(ctx context.Context) error {
+			record((<-system.Context().Ask(system.Root(), "alive")).(string))
+			return nil
+		})
+
+		Expect(system.Terminate(context.Background())).To(Succeed())
+		system.Wait()
+		Expect(log).To(Equal([]string{
+			PhaseStopAccepting, "alive", PhaseUnbind, "root stopped", PhasePersistenceFlush, PhaseClusterLeave,
+		}))
+	})
+
+	It("continues after a phase times out", func() {
+		shutdown := system.CoordinatedShutdown()
+		shutdown.SetPhaseTimeout(PhaseDrain, 10*time.Millisecond)
+		shutdown.AddTask(PhaseDrain, "stuck", func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		})
+		shutdown.AddTask(PhaseClusterLeave, "leave", func(ctx context.Context) error {
+			record("left")
+			return nil
+		})
+
+		err := system.Terminate(context.Background())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("shutdown phase drain")))
+		Expect(log).To(Equal([]string{"root stopped", "left"}))
+	})
+
+	It("returns the first task error once to all callers", func() {
+		shutdown := system.CoordinatedShutdown()
+		shutdown.AddTask(PhaseUnbind, "unbind", func(ctx context.Context) error {
+			return errors.New("busy")
+		})
+
+		results := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				results <- system.Terminate(context.Background())
+			}()
+		}
+		Expect(<-results).To(MatchError("shutdown phase unbind, task unbind: busy"))
+		Expect(<-results).To(MatchError("shutdown phase unbind, task unbind: busy"))
+		Expect(system.Terminate(context.Background())).To(MatchError("shutdown phase unbind, task unbind: busy"))
+		Expect(log).To(Equal([]string{"root stopped"}))
+	})
+
+	It("rejects unknown phases", func() {
+		Expect(func() {
+			system.CoordinatedShutdown().AddTask("unknown", "task", nil)
+		}).To(Panic())
+		Expect(system.Terminate(context.Background())).To(Succeed())
+	})
+})
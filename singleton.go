@@ -0,0 +1,140 @@
+package tractor
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const singletonRestartDelay = 100 * time.Millisecond
+
+type SingletonSettings struct {
+	// TerminationMessage is sent to the singleton when it is handed over to a different node.
+	// The singleton is expected to return Stopped() when it receives it.
+	TerminationMessage interface{}
+	// BufferSize limits the number of messages buffered by the proxy while the singleton is relocating.
+	BufferSize int
+}
+
+// SingletonLeave gracefully hands over the singleton and stops the manager.
+type SingletonLeave struct{}
+
+type identifySingleton struct{}
+
+type singletonIdentified struct {
+	ref ActorRef
+}
+
+type singletonTerminated struct {
+	ref ActorRef
+}
+
+type restartSingleton struct{}
+
+// SingletonManager runs the singleton on the oldest node of the membership. Every node is expected to start
+// its own manager.
+func SingletonManager(membership ActorRef, singleton SetupHandler, settings SingletonSettings) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		membership.Tell(ctx, joinMembership{})
+
+		oldest := false
+		leaving := false
+		var ref ActorRef
+		var waiting []ActorRef
+
+		start := func() {
+			ref = ctx.Spawn(singleton)
+			ctx.WatchWith(ref, singletonTerminated{ref: ref})
+			for _, w := range waiting {
+				w.Tell(ctx, singletonIdentified{ref: ref})
+			}
+			waiting = nil
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case membersChanged:
+				if !oldest && len(m.members) > 0 && m.members[0] == ctx.Self() {
+					oldest = true
+					start()
+				}
+			case identifySingleton:
+				switch {
+				case leaving:
+					// the singleton is stopping, proxies identify it on the next oldest node once this one left
+				case ref != nil:
+					ctx.Sender().Tell(ctx, singletonIdentified{ref: ref})
+				default:
+					waiting = append(waiting, ctx.Sender())
+				}
+			case singletonTerminated:
+				ref = nil
+				if leaving {
+					return Stopped()
+				}
+				scheduleOnce(ctx, singletonRestartDelay, restartSingleton{})
+			case restartSingleton:
+				if ref == nil && !leaving {
+					start()
+				}
+			case SingletonLeave:
+				leaving = true
+				if ref == nil {
+					return Stopped()
+				}
+				ref.Tell(ctx, settings.TerminationMessage)
+			}
+			return nil
+		}
+	}
+}
+
+// SingletonProxy routes messages to the singleton wherever it runs and buffers them while
+// the singleton is relocating.
+func SingletonProxy(membership ActorRef, settings SingletonSettings) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		membership.Tell(ctx, subscribeMembers{})
+
+		var oldest ActorRef
+		var singleton ActorRef
+		var buffer []envelope
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case membersChanged:
+				if len(m.members) > 0 && m.members[0] != oldest {
+					oldest = m.members[0]
+					oldest.Tell(ctx, identifySingleton{})
+				}
+			case singletonIdentified:
+				if m.ref != singleton {
+					singleton = m.ref
+					ctx.WatchWith(singleton, singletonTerminated{ref: singleton})
+				}
+				for _, env := range buffer {
+					forwardFrom(ctx, env.sender, singleton, env.msg)
+				}
+				buffer = nil
+			case singletonTerminated:
+				if m.ref != singleton {
+					return nil
+				}
+				singleton = nil
+				if oldest != nil {
+					oldest.Tell(ctx, identifySingleton{})
+				}
+			default:
+				if singleton != nil {
+					forward(ctx, singleton, msg)
+					return nil
+				}
+				if settings.BufferSize > 0 && len(buffer) >= settings.BufferSize {
+					_, _ = fmt.Fprintf(os.Stderr, "singleton proxy: buffer is full, dropping %T\n", buffer[0].msg)
+					buffer = buffer[1:]
+				}
+				buffer = append(buffer, envelope{sender: ctx.Sender(), msg: msg})
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tractor
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type singletonStop struct{}
+
+func countingSingleton(running *int32, started *int32) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		Expect(atomic.AddInt32(running, 1)).To(Equal(int32(1)))
+		n := atomic.AddInt32(started, 1)
+		return func(msg interface{}) MessageHandler {
+			switch msg.(type) {
+			case singletonStop:
+				atomic.AddInt32(running, -1)
+				return Stopped()
+			default:
+				ctx.Sender().Tell(ctx, n)
+			}
+			return nil
+		}
+	}
+}
+
+var _ = Describe("Singleton", func() {
+	settings := SingletonSettings{TerminationMessage: singletonStop{}, BufferSize: 10}
+
+	It("runs a single instance on the oldest node", func() {
+		var running, started int32
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			for i := 0; i < 3; i++ {
+				ctx.Spawn(SingletonManager(membership, countingSingleton(&running, &started), settings))
+			}
+			proxy := ctx.Spawn(SingletonProxy(membership, settings))
+
+			Expect(<-ctx.Ask(proxy, "ping")).To(Equal(int32(1)))
+			Expect(<-ctx.Ask(proxy, "ping")).To(Equal(int32(1)))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(started).To(Equal(int32(1)))
+	})
+
+	It("hands over to the next oldest node on leave", func() {
+		var running, started int32
+		root := func(ctx ActorContext) MessageHandler {
+			membership := ctx.Spawn(ClusterMembership())
+			first := ctx.Spawn(SingletonManager(membership, countingSingleton(&running, &started), settings))
+			proxy := ctx.Spawn(SingletonProxy(membership, settings))
+			Expect(<-ctx.Ask(proxy, "ping")).To(Equal(int32(1)))
+
+			ctx.Spawn(SingletonManager(membership, countingSingleton(&running, &started), settings))
+			// the proxy watched the singleton before the root, so it is notified of the termination first
+			singleton := (<-ctx.Ask(first, identifySingleton{})).(singletonIdentified).ref
+			ctx.Watch(singleton)
+			first.Tell(ctx, SingletonLeave{})
+
+			return func(msg interface{}) MessageHandler {
+				if _, ok := msg.(Terminated); ok {
+					Eventually(ctx.Ask(proxy, "ping"), time.Second).Should(Receive(Equal(int32(2))))
+					return Stopped()
+				}
+				return nil
+			}
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(started).To(Equal(int32(2)))
+	})
+
+	It("identifies the singleton only while it runs", func() {
+		kit := NewBehaviorTestKit(SingletonManager(NewTestInbox(), countingSingleton(new(int32), new(int32)), settings))
+		kit.Effects()
+		proxy := NewTestInbox()
+		kit.RunFrom(proxy, identifySingleton{})
+		Expect(proxy.HasMessages()).To(BeFalse())
+
+		kit.Run(membersChanged{members: []ActorRef{kit.Ref()}})
+		singleton := kit.Effects()[0].(SpawnedEffect).Inbox
+		Expect(proxy.Messages()).To(Equal([]interface{}{singletonIdentified{ref: singleton}}))
+
+		kit.Run(SingletonLeave{})
+		Expect(singleton.Messages()).To(Equal([]interface{}{singletonStop{}}))
+		kit.RunFrom(proxy, identifySingleton{})
+		Expect(proxy.HasMessages()).To(BeFalse())
+		kit.Run(singletonTerminated{ref: singleton})
+		Expect(kit.IsAlive()).To(BeFalse())
+	})
+})
@@ -0,0 +1,154 @@
+package tractor
+
+import "fmt"
+
+// StashOverflowStrategy decides what happens to a message stashed into a full stash.
+type StashOverflowStrategy int
+
+const (
+	// StashOverflowFail panics with a StashOverflow error, failing the actor unless it is supervised.
+	StashOverflowFail StashOverflowStrategy = iota
+	// StashOverflowDropNew publishes the new message as a dead letter.
+	StashOverflowDropNew
+	// StashOverflowDropOldest publishes the oldest stashed message as a dead letter and stashes the new one.
+	StashOverflowDropOldest
+)
+
+// StashOverflow is the panic value of Stash when the stash is full and the strategy is StashOverflowFail.
+type StashOverflow struct {
+	Capacity int
+	Message  interface{}
+}
+
+func (e StashOverflow) Error() string {
+	return fmt.Sprintf("stash overflow: capacity %d exceeded by %T", e.Capacity, e.Message)
+}
+
+// StashOption configures a stash created with NewStash.
+type StashOption func(settings *stashSettings)
+
+type stashSettings struct {
+	overflow StashOverflowStrategy
+}
+
+// OnStashOverflow sets the overflow strategy, StashOverflowFail by default.
+func OnStashOverflow(strategy StashOverflowStrategy) StashOption {
+	return func(settings *stashSettings) {
+		settings.overflow = strategy
+	}
+}
+
+// stash holds the envelopes and enforces the capacity, a non positive capacity is unbounded.
+type stash struct {
+	capacity int
+	overflow StashOverflowStrategy
+	buffer   []envelope
+	// dropped is called with envelopes dropped on overflow
+	dropped func(e envelope)
+}
+
+func newStash(capacity int, options []StashOption, dropped func(e envelope)) stash {
+	settings := stashSettings{}
+	for _, option := range options {
+		option(&settings)
+	}
+	return stash{capacity: capacity, overflow: settings.overflow, dropped: dropped}
+}
+
+func (s *stash) push(e envelope) {
+	if s.IsFull() {
+		switch s.overflow {
+		case StashOverflowDropNew:
+			s.dropped(e)
+			return
+		case StashOverflowDropOldest:
+			s.dropped(s.buffer[0])
+			s.buffer[0] = envelope{}
+			s.buffer = s.buffer[1:]
+		default:
+			panic(StashOverflow{Capacity: s.capacity, Message: e.msg})
+		}
+	}
+	s.buffer = append(s.buffer, e)
+}
+
+// take removes and returns up to count oldest envelopes.
+func (s *stash) take(count int) []envelope {
+	if count > len(s.buffer) {
+		count = len(s.buffer)
+	}
+	if count < 0 {
+		count = 0
+	}
+	taken := s.buffer[:count:count]
+	s.buffer = s.buffer[count:]
+	return taken
+}
+
+func (s *stash) Size() int {
+	return len(s.buffer)
+}
+
+func (s *stash) IsFull() bool {
+	return s.capacity > 0 && len(s.buffer) >= s.capacity
+}
+
+func (s *stash) Foreach(f func(msg interface{})) {
+	for _, e := range s.buffer {
+		f(e.msg)
+	}
+}
+
+func (s *stash) Exists(predicate func(msg interface{}) bool) bool {
+	for _, e := range s.buffer {
+		if predicate(e.msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stash) Clear() {
+	s.buffer = nil
+}
+
+func (s *stash) Head() interface{} {
+	if len(s.buffer) == 0 {
+		return nil
+	}
+	return s.buffer[0].msg
+}
+
+// stashedEnvelope keeps the sender of batch messages, other messages get the sender of the current message.
+func stashedEnvelope(ctx ActorContext, msg interface{}) envelope {
+	if m, ok := msg.(BatchMessage); ok {
+		return envelope{sender: m.Sender, msg: m.Message}
+	}
+	return envelope{sender: ctx.Sender(), msg: msg}
+}
+
+type stashBuffer struct {
+	stash
+	context *localActorContext
+}
+
+func (ctx *localActorContext) NewStash(size int, options ...StashOption) StashBuffer {
+	s := &stashBuffer{context: ctx}
+	s.stash = newStash(size, options, func(e envelope) {
+		ctx.system.eventStream.publish(DeadLetter{Message: e.msg, Sender: e.sender, Recipient: ctx.self})
+	})
+	return s
+}
+
+func (s *stashBuffer) Stash(msg interface{}) {
+	s.push(stashedEnvelope(s.context, msg))
+}
+
+func (s *stashBuffer) UnstashAll(handler MessageHandler) MessageHandler {
+	return s.Unstash(handler, len(s.buffer))
+}
+
+func (s *stashBuffer) Unstash(handler MessageHandler, count int) MessageHandler {
+	s.context.mailbox.unstashAll(s.take(count))
+	return handler
+}
@@ -0,0 +1,117 @@
+package tractor
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// stashing stashes every message except "open", which unstashes them to the sender.
+func stashing(stash *StashBuffer, capacity int, options ...StashOption) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		*stash = ctx.NewStash(capacity, options...)
+		return func(msg interface{}) MessageHandler {
+			if msg == "open" {
+				return (*stash).UnstashAll(func(msg interface{}) MessageHandler {
+					ctx.Sender().Tell(ctx, msg)
+					return nil
+				})
+			}
+			(*stash).Stash(msg)
+			return nil
+		}
+	}
+}
+
+var _ = Describe("Stash", func() {
+	It("inspects stashed messages", func() {
+		var stash StashBuffer
+		kit := NewBehaviorTestKit(stashing(&stash, 2))
+		Expect(stash.Head()).To(BeNil())
+		kit.Run("a")
+		kit.Run("b")
+		Expect(stash.Size()).To(Equal(2))
+		Expect(stash.IsFull()).To(BeTrue())
+		Expect(stash.Head()).To(Equal("a"))
+		Expect(stash.Exists(func(msg interface{}) bool { return msg == "b" })).To(BeTrue())
+		Expect(stash.Exists(func(msg interface{}) bool { return msg == "c" })).To(BeFalse())
+		var all []interface{}
+		stash.Foreach(func(msg interface{}) { all = append(all, msg) })
+		Expect(all).To(Equal([]interface{}{"a", "b"}))
+
+		stash.Clear()
+		Expect(stash.Size()).To(Equal(0))
+		Expect(stash.IsFull()).To(BeFalse())
+	})
+
+	It("unstashes at most the stashed messages", func() {
+		var stash StashBuffer
+		kit := NewBehaviorTestKit(stashing(&stash, 10))
+		inbox := NewTestInbox()
+		kit.RunFrom(inbox, "a")
+		kit.RunFrom(inbox, "b")
+		stash.Unstash(kit.Handler(), 1)
+		Expect(stash.Size()).To(Equal(1))
+		Expect(stash.Head()).To(Equal("b"))
+		stash.Unstash(kit.Handler(), 10)
+		Expect(stash.Size()).To(Equal(0))
+	})
+
+	It("fails on overflow by default", func() {
+		var stash StashBuffer
+		kit := NewBehaviorTestKit(stashing(&stash, 1))
+		kit.Run("a")
+		Expect(func() { kit.Run("b") }).To(PanicWith(StashOverflow{Capacity: 1, Message: "b"}))
+		Expect(stash.Size()).To(Equal(1))
+	})
+
+	It("drops messages on overflow", func() {
+		for strategy, dropped := range map[StashOverflowStrategy]interface{}{
+			StashOverflowDropNew:    "c",
+			StashOverflowDropOldest: "a",
+		} {
+			system := Start(stashing(new(StashBuffer), 2, OnStashOverflow(strategy)))
+			probe := NewTestProbe(GinkgoT(), system)
+			events, ch := ToChannel(system.Context(), 10)
+			system.Context().EventStream().Subscribe(events, DeadLetter{})
+
+			for _, msg := range []string{"a", "b", "c"} {
+				probe.Send(system.Root(), msg)
+			}
+			Eventually(ch).Should(Receive(Equal(DeadLetter{Message: dropped, Sender: probe, Recipient: system.Root()})))
+			probe.Send(system.Root(), "open")
+			if strategy == StashOverflowDropNew {
+				probe.ExpectMessage("a", time.Second)
+				probe.ExpectMessage("b", time.Second)
+			} else {
+				probe.ExpectMessage("b", time.Second)
+				probe.ExpectMessage("c", time.Second)
+			}
+			Expect(system.Terminate(context.Background())).To(Succeed())
+		}
+	})
+
+	It("stashes signals", func() {
+		var received []interface{}
+		runActor(func(ctx ActorContext) MessageHandler {
+			ctx.DeliverSignals(true)
+			stash := ctx.NewStash(10)
+			return func(msg interface{}) MessageHandler {
+				switch msg.(type) {
+				case PostInitSignal:
+					stash.Stash(msg)
+					return nil
+				}
+				return stash.UnstashAll(func(msg interface{}) MessageHandler {
+					if _, ok := msg.(PostInitSignal); ok {
+						received = append(received, ctx.Sender() == nil)
+					}
+					return recording(&received)(msg)
+				})
+			}
+		}, "open", "a", "stop")
+		Expect(received).To(Equal([]interface{}{true, PostInitSignal{}, "a", PreStopSignal{}, PostStopSignal{}}))
+	})
+})
@@ -0,0 +1,63 @@
+package tractor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// PoisonPill stops the actor after it processed all messages queued before it. It isn't delivered to the handler.
+type PoisonPill struct{}
+
+// ErrStopTimeout is returned by GracefulStop when the actor didn't terminate in time.
+var ErrStopTimeout = errors.New("actor didn't stop in time")
+
+// GracefulStop tells stopMsg to the actor and waits until it terminated. The actor is expected to stop itself
+// when it handles stopMsg, PoisonPill can be used to stop it once it processed its mailbox. Replies to the sender
+// of stopMsg are published as dead letters. It must not be called from actors running on a DeterministicDispatcher.
+func GracefulStop(ref ActorRef, timeout time.Duration, stopMsg interface{}) error {
+	local, ok := ref.(*localActorRef)
+	if !ok {
+		return fmt.Errorf("can't stop %T", ref)
+	}
+	ctx := local.context
+	expired := make(chan struct{})
+	timer := ctx.system.clock.AfterFunc(timeout, func() {
+		close(expired)
+	})
+	defer timer.Stop()
+
+	ctx.tell(envelope{sender: deadLetterRef{events: ctx.system.eventStream}, msg: stopMsg}, ctx.done)
+	select {
+	case <-ctx.done:
+		return nil
+	case <-expired:
+		return ErrStopTimeout
+	}
+}
+
+// deadLetterRef publishes told messages as dead letters.
+type deadLetterRef struct {
+	events *eventStream
+}
+
+func (ref deadLetterRef) Tell(ctx ActorContext, msg interface{}) {
+	ref.events.publish(DeadLetter{Message: msg, Sender: ctx.Self(), Recipient: ref})
+}
+
+// Stop stops the child immediately, or the actor itself once the current handler returns. Messages in the mailbox
+// are dropped.
+func (ctx *localActorContext) Stop(ref ActorRef) {
+	if ref == ActorRef(ctx.self) {
+		ctx.stopSelf = true
+		return
+	}
+	for _, child := range ctx.children {
+		if ref == ActorRef(child) {
+			child.context.tellCommand(&terminateCommand{})
+			return
+		}
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "actor %p: can't stop %v, it isn't a child\n", ctx.self, ref)
+}
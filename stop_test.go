@@ -0,0 +1,151 @@
+package tractor
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stopping", func() {
+	var system ActorSystem
+	var probe *TestProbe
+	var entered, gate chan struct{}
+
+	// forwarding tells every message to the probe, waiting for the gate before the first one.
+	forwarding := func(ctx ActorContext) MessageHandler {
+		first := true
+		return func(msg interface{}) MessageHandler {
+			if first {
+				first = false
+				close(entered)
+				<-gate
+			}
+			probe.Tell(ctx, msg)
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		system = Start(echo)
+		probe = NewTestProbe(GinkgoT(), system)
+		entered = make(chan struct{})
+		gate = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		Expect(system.Terminate(context.Background())).To(Succeed())
+	})
+
+	It("stops children immediately", func() {
+		children := make(chan ActorRef, 1)
+		parent := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			child := ctx.Spawn(forwarding)
+			children <- child
+			return func(msg interface{}) MessageHandler {
+				ctx.Stop(child)
+				return nil
+			}
+		})
+		child := <-children
+		probe.Watch(child)
+		for i := 0; i < 3; i++ {
+			child.Tell(system.Context(), i)
+		}
+		<-entered
+		parent.Tell(system.Context(), "stop child")
+		Eventually(func() bool {
+			return atomic.LoadInt32(&child.(*localActorRef).context.mailbox.pendingCount) > 0
+		}).Should(BeTrue())
+		close(gate)
+
+		probe.ExpectMessage(0, time.Second)
+		probe.ExpectTerminated(child, time.Second)
+		probe.ExpectNoMessage(10 * time.Millisecond)
+	})
+
+	It("stops itself after the handler returns", func() {
+		ref := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			ctx.DeliverSignals(true)
+			return func(msg interface{}) MessageHandler {
+				if msg == "stop" {
+					ctx.Stop(ctx.Self())
+				}
+				probe.Tell(ctx, msg)
+				return nil
+			}
+		})
+		probe.Watch(ref)
+		probe.Send(ref, "stop")
+		probe.ExpectMessage(PostInitSignal{}, time.Second)
+		probe.ExpectMessage("stop", time.Second)
+		probe.ExpectMessage(PreStopSignal{}, time.Second)
+		probe.ExpectMessage(PostStopSignal{}, time.Second)
+		probe.ExpectTerminated(ref, time.Second)
+	})
+
+	It("processes messages queued before the PoisonPill", func() {
+		ref := system.Context().Spawn(forwarding)
+		probe.Watch(ref)
+		events, ch := ToChannel(system.Context(), 10)
+		system.Context().EventStream().Subscribe(events, DeadLetter{})
+
+		ref.Tell(system.Context(), 1)
+		ref.Tell(system.Context(), 2)
+		ref.Tell(system.Context(), PoisonPill{})
+		ref.Tell(system.Context(), 3)
+		close(gate)
+
+		probe.ExpectMessage(1, time.Second)
+		probe.ExpectMessage(2, time.Second)
+		probe.ExpectTerminated(ref, time.Second)
+		Eventually(ch).Should(Receive(Equal(DeadLetter{Message: 3, Sender: system.Context().Self(), Recipient: ref})))
+	})
+
+	It("stops gracefully", func() {
+		ref := system.Context().Spawn(forwarding)
+		ref.Tell(system.Context(), 1)
+		close(gate)
+		Expect(GracefulStop(ref, time.Second, PoisonPill{})).To(Succeed())
+		probe.ExpectMessage(1, time.Second)
+		Expect(GracefulStop(ref, time.Second, PoisonPill{})).To(Succeed())
+	})
+
+	It("publishes replies to the stop message as dead letters", func() {
+		ref := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				ctx.Sender().Tell(ctx, "stopping")
+				return Stopped()
+			}
+		})
+		events, ch := ToChannel(system.Context(), 10)
+		system.Context().EventStream().Subscribe(events, DeadLetter{})
+		Expect(GracefulStop(ref, time.Second, "stop")).To(Succeed())
+		var letter DeadLetter
+		Eventually(ch).Should(Receive(&letter))
+		Expect(letter.Message).To(Equal("stopping"))
+		Expect(letter.Sender).To(Equal(ref))
+	})
+
+	It("times out when the actor doesn't stop", func() {
+		clock := NewManualClock(time.Now())
+		system := Start(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				if msg == "stop" {
+					return Stopped()
+				}
+				return nil
+			}
+		}, WithClock(clock))
+		stopped := make(chan error)
+		go func() {
+			stopped <- GracefulStop(system.Root(), time.Minute, "ignored")
+		}()
+		Eventually(clock.PendingTimers).Should(Equal(1))
+		clock.Advance(time.Minute)
+		Expect(<-stopped).To(Equal(ErrStopTimeout))
+		Expect(GracefulStop(system.Root(), time.Minute, "stop")).To(Succeed())
+	})
+})
@@ -0,0 +1,409 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/mikea/tractor"
+)
+
+func FromSlice(elems ...interface{}) Source {
+	return FromIterator(func() func() (interface{}, bool) {
+		i := 0
+		return func() (interface{}, bool) {
+			if i == len(elems) {
+				return nil, false
+			}
+			i++
+			return elems[i-1], true
+		}
+	})
+}
+
+// Range emits integers from start to end exclusive.
+func Range(start, end int) Source {
+	return FromIterator(func() func() (interface{}, bool) {
+		i := start
+		return func() (interface{}, bool) {
+			if i >= end {
+				return nil, false
+			}
+			i++
+			return i - 1, true
+		}
+	})
+}
+
+// FromIterator emits elements returned by the iterator until it returns false.
+// The factory is invoked for every materialization.
+func FromIterator(factory func() func() (interface{}, bool)) Source {
+	return Source{materialize: func(ctx tractor.ActorContext, downstream tractor.ActorRef) {
+		ctx.Spawn(sourceStage(factory(), downstream))
+	}}
+}
+
+func flow(logic func() logic) Flow {
+	return Flow{materialize: func(ctx tractor.ActorContext, downstream tractor.ActorRef) tractor.ActorRef {
+		return ctx.Spawn(newStage(logic, 1, downstream))
+	}}
+}
+
+type mapLogic struct {
+	f func(elem interface{}) interface{}
+}
+
+func (l *mapLogic) onPush(s *stage, _ int, elem interface{}) {
+	s.push(l.f(elem))
+}
+
+func (l *mapLogic) onUpstreamFinish(s *stage, _ int) {
+	s.complete()
+}
+
+func (l *mapLogic) capacity(s *stage, _ int) int {
+	return s.free()
+}
+
+func (l *mapLogic) onMessage(*stage, interface{}) {}
+
+func Map(f func(elem interface{}) interface{}) Flow {
+	return flow(func() logic { return &mapLogic{f: f} })
+}
+
+func Filter(p func(elem interface{}) bool) Flow {
+	return flow(func() logic { return &filterLogic{p: p} })
+}
+
+type filterLogic struct {
+	p func(elem interface{}) bool
+}
+
+func (l *filterLogic) onPush(s *stage, _ int, elem interface{}) {
+	if l.p(elem) {
+		s.push(elem)
+	}
+}
+
+func (l *filterLogic) onUpstreamFinish(s *stage, _ int) {
+	s.complete()
+}
+
+func (l *filterLogic) capacity(s *stage, _ int) int {
+	return s.free()
+}
+
+func (l *filterLogic) onMessage(*stage, interface{}) {}
+
+type groupedLogic struct {
+	n     int
+	group []interface{}
+}
+
+func (l *groupedLogic) onPush(s *stage, _ int, elem interface{}) {
+	l.group = append(l.group, elem)
+	if len(l.group) == l.n {
+		s.push(l.group)
+		l.group = nil
+	}
+}
+
+func (l *groupedLogic) onUpstreamFinish(s *stage, _ int) {
+	if len(l.group) > 0 {
+		s.push(l.group)
+		l.group = nil
+	}
+	s.complete()
+}
+
+func (l *groupedLogic) capacity(s *stage, _ int) int {
+	return s.free()
+}
+
+func (l *groupedLogic) onMessage(*stage, interface{}) {}
+
+// Grouped emits slices of n elements, the last one might be shorter.
+func Grouped(n int) Flow {
+	return flow(func() logic { return &groupedLogic{n: n} })
+}
+
+type asyncResult struct {
+	index int
+	value interface{}
+	err   error
+}
+
+type mapAsyncLogic struct {
+	parallelism int
+	f           func(elem interface{}) (interface{}, error)
+	started     int
+	emitted     int
+	results     map[int]asyncResult
+	finished    bool
+}
+
+func (l *mapAsyncLogic) onPush(s *stage, _ int, elem interface{}) {
+	index := l.started
+	l.started++
+	self := s.ctx.Self()
+	ctx := s.ctx
+	go func() {
+		result := asyncResult{index: index}
+		defer func() {
+			if err := recover(); err != nil {
+				result.err = recovered(err)
+			}
+			self.Tell(ctx, result)
+		}()
+		result.value, result.err = l.f(elem)
+	}()
+}
+
+func (l *mapAsyncLogic) onUpstreamFinish(s *stage, _ int) {
+	l.finished = true
+	if l.started == l.emitted {
+		s.complete()
+	}
+}
+
+func (l *mapAsyncLogic) capacity(s *stage, _ int) int {
+	n := l.parallelism - (l.started - l.emitted)
+	if free := s.free(); free < n {
+		n = free
+	}
+	return n
+}
+
+func (l *mapAsyncLogic) onMessage(s *stage, msg interface{}) {
+	r, ok := msg.(asyncResult)
+	if !ok {
+		return
+	}
+	if r.err != nil {
+		s.fail(r.err)
+		return
+	}
+	l.results[r.index] = r
+	for {
+		next, ok := l.results[l.emitted]
+		if !ok {
+			break
+		}
+		delete(l.results, l.emitted)
+		l.emitted++
+		s.push(next.value)
+	}
+	if l.finished && l.started == l.emitted {
+		s.complete()
+	}
+}
+
+// MapAsync invokes f on a separate goroutine for up to parallelism elements at a time and emits the results
+// in the order of the elements. An error fails the stream.
+func MapAsync(parallelism int, f func(elem interface{}) (interface{}, error)) Flow {
+	return flow(func() logic { return &mapAsyncLogic{parallelism: parallelism, f: f, results: map[int]asyncResult{}} })
+}
+
+type throttleTick struct{}
+
+type throttleLogic struct {
+	elements int
+	per      time.Duration
+	tokens   int
+	queue    []interface{}
+	finished bool
+	timers   tractor.TimerScheduler
+}
+
+func (l *throttleLogic) setTimers(timers tractor.TimerScheduler) {
+	l.timers = timers
+}
+
+func (l *throttleLogic) drain(s *stage) {
+	for l.tokens > 0 && len(l.queue) > 0 {
+		l.tokens--
+		elem := l.queue[0]
+		l.queue = l.queue[1:]
+		s.push(elem)
+	}
+	if l.finished && len(l.queue) == 0 {
+		l.timers.Cancel(throttleTick{})
+		s.complete()
+		return
+	}
+	if l.tokens < l.elements && !l.timers.IsTimerActive(throttleTick{}) {
+		l.timers.StartSingleTimer(throttleTick{}, throttleTick{}, l.per)
+	}
+}
+
+func (l *throttleLogic) onPush(s *stage, _ int, elem interface{}) {
+	l.queue = append(l.queue, elem)
+	l.drain(s)
+}
+
+func (l *throttleLogic) onUpstreamFinish(s *stage, _ int) {
+	l.finished = true
+	l.drain(s)
+}
+
+func (l *throttleLogic) capacity(s *stage, _ int) int {
+	return s.free() - len(l.queue)
+}
+
+func (l *throttleLogic) onMessage(s *stage, msg interface{}) {
+	if _, ok := msg.(throttleTick); ok {
+		l.tokens = l.elements
+		l.drain(s)
+		s.pull()
+	}
+}
+
+// Throttle emits at most elements per time period.
+func Throttle(elements int, per time.Duration) Flow {
+	return flow(func() logic { return &throttleLogic{elements: elements, per: per, tokens: elements} })
+}
+
+func (s Source) Throttle(elements int, per time.Duration) Source {
+	return s.Via(Throttle(elements, per))
+}
+
+type mergeLogic struct{}
+
+func (l *mergeLogic) onPush(s *stage, _ int, elem interface{}) {
+	s.push(elem)
+}
+
+func (l *mergeLogic) onUpstreamFinish(s *stage, _ int) {
+	if s.allFinished() {
+		s.complete()
+	}
+}
+
+func (l *mergeLogic) capacity(s *stage, _ int) int {
+	return s.free()
+}
+
+func (l *mergeLogic) onMessage(*stage, interface{}) {}
+
+func junction(logic func() logic, sources []Source) Source {
+	return Source{materialize: func(ctx tractor.ActorContext, downstream tractor.ActorRef) {
+		ref := ctx.Spawn(newStage(logic, len(sources), downstream))
+		for i, source := range sources {
+			source.materialize(ctx, portRef{target: ref, port: i})
+		}
+	}}
+}
+
+// Merge emits elements of all sources as they arrive and completes when all sources complete.
+func Merge(sources ...Source) Source {
+	return junction(func() logic { return &mergeLogic{} }, sources)
+}
+
+type zipLogic struct {
+	queues [][]interface{}
+}
+
+func (l *zipLogic) onPush(s *stage, port int, elem interface{}) {
+	l.queues[port] = append(l.queues[port], elem)
+	for {
+		for _, q := range l.queues {
+			if len(q) == 0 {
+				l.completeIfExhausted(s)
+				return
+			}
+		}
+		tuple := make([]interface{}, len(l.queues))
+		for i := range l.queues {
+			tuple[i] = l.queues[i][0]
+			l.queues[i] = l.queues[i][1:]
+		}
+		s.push(tuple)
+	}
+}
+
+// completeIfExhausted completes the stage when a finished upstream has no more elements to zip.
+func (l *zipLogic) completeIfExhausted(s *stage) {
+	for port, q := range l.queues {
+		if len(q) == 0 && s.finished[port] {
+			s.complete()
+			return
+		}
+	}
+}
+
+func (l *zipLogic) onUpstreamFinish(s *stage, _ int) {
+	l.completeIfExhausted(s)
+}
+
+func (l *zipLogic) capacity(s *stage, port int) int {
+	return s.free() - len(l.queues[port])
+}
+
+func (l *zipLogic) onMessage(*stage, interface{}) {}
+
+// Zip emits slices combining one element of every source and completes when any source completes.
+func Zip(sources ...Source) Source {
+	return junction(func() logic { return &zipLogic{queues: make([][]interface{}, len(sources))} }, sources)
+}
+
+// Broadcast emits every element to all sinks, and is backpressured by the slowest one. The result value
+// is a slice of values of all sinks.
+func Broadcast(sinks ...Sink) Sink {
+	return Sink{materialize: func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result) {
+		refs := make([]tractor.ActorRef, len(sinks))
+		results := make([]<-chan Result, len(sinks))
+		for i, sink := range sinks {
+			refs[i], results[i] = sink.materialize(ctx)
+		}
+		result := make(chan Result, 1)
+		go func() {
+			values := make([]interface{}, len(results))
+			var err error
+			for i, r := range results {
+				v := <-r
+				values[i] = v.Value
+				if err == nil {
+					err = v.Err
+				}
+			}
+			result <- Result{Value: values, Err: err}
+		}()
+		return ctx.Spawn(broadcastStage(refs)), result
+	}}
+}
+
+func sink(onElem func(elem interface{}), onDone func() interface{}) Sink {
+	return Sink{materialize: func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result) {
+		result := make(chan Result, 1)
+		return ctx.Spawn(sinkStage(onElem, onDone, result)), result
+	}}
+}
+
+func ForEach(f func(elem interface{})) Sink {
+	return sink(f, func() interface{} { return nil })
+}
+
+func Ignore() Sink {
+	return ForEach(func(interface{}) {})
+}
+
+// Seq collects all elements into a slice.
+func Seq() Sink {
+	return Sink{materialize: func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result) {
+		var elems []interface{}
+		return sink(func(elem interface{}) {
+			elems = append(elems, elem)
+		}, func() interface{} {
+			return elems
+		}).materialize(ctx)
+	}}
+}
+
+func Fold(zero interface{}, f func(acc interface{}, elem interface{}) interface{}) Sink {
+	return Sink{materialize: func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result) {
+		acc := zero
+		return sink(func(elem interface{}) {
+			acc = f(acc, elem)
+		}, func() interface{} {
+			return acc
+		}).materialize(ctx)
+	}}
+}
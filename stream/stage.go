@@ -0,0 +1,326 @@
+package stream
+
+import (
+	"github.com/mikea/tractor"
+)
+
+// logic implements the processing of a stage, while the stage takes care of the demand.
+type logic interface {
+	onPush(s *stage, port int, elem interface{})
+	onUpstreamFinish(s *stage, port int)
+	// capacity returns how many more elements the logic can accept from the port
+	capacity(s *stage, port int) int
+	onMessage(s *stage, msg interface{})
+}
+
+// timedLogic is implemented by logics that schedule messages to their stage. Timers are cancelled when the stage
+// stops.
+type timedLogic interface {
+	logic
+	setTimers(timers tractor.TimerScheduler)
+}
+
+type stage struct {
+	ctx        tractor.ActorContext
+	logic      logic
+	downstream tractor.ActorRef
+	upstreams  []tractor.ActorRef
+	finished   []bool
+	inFlight   []int
+	demand     int
+	buffer     []interface{}
+	completing bool
+	stopped    bool
+}
+
+func newStage(logic func() logic, ports int, downstream tractor.ActorRef) tractor.SetupHandler {
+	return func(ctx tractor.ActorContext) tractor.MessageHandler {
+		s := &stage{
+			ctx:        ctx,
+			logic:      logic(),
+			downstream: downstream,
+			upstreams:  make([]tractor.ActorRef, ports),
+			finished:   make([]bool, ports),
+			inFlight:   make([]int, ports),
+		}
+		start := func(ctx tractor.ActorContext) tractor.MessageHandler {
+			downstream.Tell(ctx, onSubscribe{})
+			return s.handle
+		}
+		if timed, ok := s.logic.(timedLogic); ok {
+			return tractor.WithTimers(func(timers tractor.TimerScheduler) tractor.SetupHandler {
+				timed.setTimers(timers)
+				return start
+			})(ctx)
+		}
+		return start(ctx)
+	}
+}
+
+func (s *stage) handle(msg interface{}) tractor.MessageHandler {
+	switch m := msg.(type) {
+	case portMessage:
+		s.onUpstream(m.port, m.msg)
+	case onSubscribe, onNext, onComplete, onError:
+		s.onUpstream(0, msg)
+	case request:
+		s.demand += m.n
+		s.emit()
+	case cancel:
+		s.cancelUpstreams()
+		s.stopped = true
+	default:
+		s.safely(func() { s.logic.onMessage(s, msg) })
+	}
+	if s.stopped {
+		return tractor.Stopped()
+	}
+	return nil
+}
+
+func (s *stage) onUpstream(port int, msg interface{}) {
+	switch m := msg.(type) {
+	case onSubscribe:
+		s.upstreams[port] = s.ctx.Sender()
+		s.pull()
+	case onNext:
+		s.inFlight[port]--
+		if s.completing {
+			return
+		}
+		s.safely(func() { s.logic.onPush(s, port, m.elem) })
+		s.pull()
+	case onComplete:
+		s.finished[port] = true
+		s.safely(func() { s.logic.onUpstreamFinish(s, port) })
+	case onError:
+		s.finished[port] = true
+		s.fail(m.err)
+	}
+}
+
+func (s *stage) safely(f func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.fail(recovered(err))
+		}
+	}()
+	f()
+}
+
+// free returns the space left in the output buffer.
+func (s *stage) free() int {
+	return defaultBufferSize - len(s.buffer)
+}
+
+func (s *stage) pull() {
+	if s.completing || s.stopped {
+		return
+	}
+	for port, upstream := range s.upstreams {
+		if upstream == nil || s.finished[port] {
+			continue
+		}
+		n := s.logic.capacity(s, port) - s.inFlight[port]
+		// request in batches unless the upstream has nothing to do
+		if n > 0 && (n >= defaultBufferSize/2 || s.inFlight[port] == 0) {
+			s.inFlight[port] += n
+			upstream.Tell(s.ctx, request{n: n})
+		}
+	}
+}
+
+func (s *stage) push(elem interface{}) {
+	s.buffer = append(s.buffer, elem)
+	s.emit()
+}
+
+func (s *stage) emit() {
+	for s.demand > 0 && len(s.buffer) > 0 {
+		s.downstream.Tell(s.ctx, onNext{elem: s.buffer[0]})
+		s.buffer = s.buffer[1:]
+		s.demand--
+	}
+	if s.completing && !s.stopped && len(s.buffer) == 0 {
+		s.downstream.Tell(s.ctx, onComplete{})
+		s.stopped = true
+	}
+	s.pull()
+}
+
+// complete finishes the stage once the buffered elements are emitted.
+func (s *stage) complete() {
+	s.completing = true
+	s.cancelUpstreams()
+	s.emit()
+}
+
+func (s *stage) fail(err error) {
+	if s.stopped {
+		return
+	}
+	s.downstream.Tell(s.ctx, onError{err: err})
+	s.cancelUpstreams()
+	s.stopped = true
+}
+
+func (s *stage) cancelUpstreams() {
+	for port, upstream := range s.upstreams {
+		if upstream != nil && !s.finished[port] {
+			s.finished[port] = true
+			upstream.Tell(s.ctx, cancel{})
+		}
+	}
+}
+
+func (s *stage) allFinished() bool {
+	for _, finished := range s.finished {
+		if !finished {
+			return false
+		}
+	}
+	return true
+}
+
+func sourceStage(next func() (interface{}, bool), downstream tractor.ActorRef) tractor.SetupHandler {
+	return func(ctx tractor.ActorContext) tractor.MessageHandler {
+		downstream.Tell(ctx, onSubscribe{})
+		return func(msg interface{}) tractor.MessageHandler {
+			switch m := msg.(type) {
+			case request:
+				for i := 0; i < m.n; i++ {
+					elem, ok := next()
+					if !ok {
+						downstream.Tell(ctx, onComplete{})
+						return tractor.Stopped()
+					}
+					downstream.Tell(ctx, onNext{elem: elem})
+				}
+			case cancel:
+				return tractor.Stopped()
+			}
+			return nil
+		}
+	}
+}
+
+func sinkStage(onElem func(elem interface{}), onDone func() interface{}, result chan<- Result) tractor.SetupHandler {
+	return func(ctx tractor.ActorContext) tractor.MessageHandler {
+		var upstream tractor.ActorRef
+		received := 0
+
+		fail := func(err error) tractor.MessageHandler {
+			result <- Result{Err: err}
+			return tractor.Stopped()
+		}
+
+		return func(msg interface{}) (next tractor.MessageHandler) {
+			switch m := msg.(type) {
+			case onSubscribe:
+				upstream = ctx.Sender()
+				upstream.Tell(ctx, request{n: defaultBufferSize})
+			case onNext:
+				defer func() {
+					if err := recover(); err != nil {
+						upstream.Tell(ctx, cancel{})
+						next = fail(recovered(err))
+					}
+				}()
+				onElem(m.elem)
+				received++
+				if received >= defaultBufferSize/2 {
+					upstream.Tell(ctx, request{n: received})
+					received = 0
+				}
+			case onComplete:
+				result <- Result{Value: onDone()}
+				return tractor.Stopped()
+			case onError:
+				return fail(m.err)
+			}
+			return nil
+		}
+	}
+}
+
+func broadcastStage(downstreams []tractor.ActorRef) tractor.SetupHandler {
+	return func(ctx tractor.ActorContext) tractor.MessageHandler {
+		for _, d := range downstreams {
+			d.Tell(ctx, onSubscribe{})
+		}
+		var upstream tractor.ActorRef
+		demand := make([]int, len(downstreams))
+		var buffer []interface{}
+		inFlight := 0
+		completing := false
+
+		pull := func() {
+			if n := defaultBufferSize - len(buffer) - inFlight; upstream != nil && !completing && n > 0 && (n >= defaultBufferSize/2 || inFlight == 0) {
+				inFlight += n
+				upstream.Tell(ctx, request{n: n})
+			}
+		}
+
+		emit := func() tractor.MessageHandler {
+			for len(buffer) > 0 {
+				for _, d := range demand {
+					if d == 0 {
+						pull()
+						return nil
+					}
+				}
+				for i, d := range downstreams {
+					d.Tell(ctx, onNext{elem: buffer[0]})
+					demand[i]--
+				}
+				buffer = buffer[1:]
+			}
+			if completing {
+				for _, d := range downstreams {
+					d.Tell(ctx, onComplete{})
+				}
+				return tractor.Stopped()
+			}
+			pull()
+			return nil
+		}
+
+		return func(msg interface{}) tractor.MessageHandler {
+			switch m := msg.(type) {
+			case onSubscribe:
+				upstream = ctx.Sender()
+				pull()
+			case onNext:
+				inFlight--
+				buffer = append(buffer, m.elem)
+				return emit()
+			case onComplete:
+				completing = true
+				return emit()
+			case onError:
+				for _, d := range downstreams {
+					d.Tell(ctx, m)
+				}
+				return tractor.Stopped()
+			case request:
+				for i, d := range downstreams {
+					if d == ctx.Sender() {
+						demand[i] += m.n
+					}
+				}
+				return emit()
+			case cancel:
+				if upstream != nil {
+					upstream.Tell(ctx, cancel{})
+				}
+				for _, d := range downstreams {
+					if d != ctx.Sender() {
+						d.Tell(ctx, onComplete{})
+					}
+				}
+				return tractor.Stopped()
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/mikea/tractor"
+)
+
+const defaultBufferSize = 16
+
+// protocol between stages: downstream requests elements from upstream, upstream never sends more than requested
+type onSubscribe struct{}
+
+type request struct {
+	n int
+}
+
+type onNext struct {
+	elem interface{}
+}
+
+type onComplete struct{}
+
+type onError struct {
+	err error
+}
+
+type cancel struct{}
+
+// portMessage is a message received by a stage with several upstreams.
+type portMessage struct {
+	port int
+	msg  interface{}
+}
+
+// portRef tags all messages sent to a stage with the upstream port.
+type portRef struct {
+	target tractor.ActorRef
+	port   int
+}
+
+func (ref portRef) Tell(ctx tractor.ActorContext, msg interface{}) {
+	ref.target.Tell(ctx, portMessage{port: ref.port, msg: msg})
+}
+
+// Result is the value materialized by a sink when the stream finishes.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// Source is a blueprint of a stage with one output.
+type Source struct {
+	materialize func(ctx tractor.ActorContext, downstream tractor.ActorRef)
+}
+
+// Flow is a blueprint of a stage with one input and one output.
+type Flow struct {
+	materialize func(ctx tractor.ActorContext, downstream tractor.ActorRef) tractor.ActorRef
+}
+
+// Sink is a blueprint of a stage with one input.
+type Sink struct {
+	materialize func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result)
+}
+
+// RunnableGraph is a fully connected stream blueprint.
+type RunnableGraph struct {
+	run func(ctx tractor.ActorContext) <-chan Result
+}
+
+// Run materializes the graph: every stage is spawned as a child of the ctx actor.
+func (g RunnableGraph) Run(ctx tractor.ActorContext) <-chan Result {
+	return g.run(ctx)
+}
+
+func (s Source) Via(flow Flow) Source {
+	return Source{materialize: func(ctx tractor.ActorContext, downstream tractor.ActorRef) {
+		s.materialize(ctx, flow.materialize(ctx, downstream))
+	}}
+}
+
+func (s Source) To(sink Sink) RunnableGraph {
+	return RunnableGraph{run: func(ctx tractor.ActorContext) <-chan Result {
+		ref, result := sink.materialize(ctx)
+		s.materialize(ctx, ref)
+		return result
+	}}
+}
+
+func (s Source) RunWith(ctx tractor.ActorContext, sink Sink) <-chan Result {
+	return s.To(sink).Run(ctx)
+}
+
+func (f Flow) Via(flow Flow) Flow {
+	return Flow{materialize: func(ctx tractor.ActorContext, downstream tractor.ActorRef) tractor.ActorRef {
+		return f.materialize(ctx, flow.materialize(ctx, downstream))
+	}}
+}
+
+func (f Flow) To(sink Sink) Sink {
+	return Sink{materialize: func(ctx tractor.ActorContext) (tractor.ActorRef, <-chan Result) {
+		ref, result := sink.materialize(ctx)
+		return f.materialize(ctx, ref), result
+	}}
+}
+
+func (s Source) Map(f func(elem interface{}) interface{}) Source {
+	return s.Via(Map(f))
+}
+
+func (s Source) Filter(p func(elem interface{}) bool) Source {
+	return s.Via(Filter(p))
+}
+
+func (s Source) MapAsync(parallelism int, f func(elem interface{}) (interface{}, error)) Source {
+	return s.Via(MapAsync(parallelism, f))
+}
+
+func (s Source) Grouped(n int) Source {
+	return s.Via(Grouped(n))
+}
+
+func recovered(err interface{}) error {
+	if e, ok := err.(error); ok {
+		return e
+	}
+	return fmt.Errorf("%v", err)
+}
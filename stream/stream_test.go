@@ -0,0 +1,169 @@
+package stream
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikea/tractor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func run(graph RunnableGraph, options ...tractor.SystemOption) Result {
+	var result Result
+	system := tractor.Start(func(ctx tractor.ActorContext) tractor.MessageHandler {
+		ch := graph.Run(ctx)
+		self := ctx.Self()
+		go func() {
+			self.Tell(ctx, <-ch)
+		}()
+		return func(msg interface{}) tractor.MessageHandler {
+			result = msg.(Result)
+			return tractor.Stopped()
+		}
+	}, options...)
+	system.Wait()
+	return result
+}
+
+func double(elem interface{}) interface{} {
+	return elem.(int) * 2
+}
+
+func ints(from, to int) []interface{} {
+	var result []interface{}
+	for i := from; i < to; i++ {
+		result = append(result, i)
+	}
+	return result
+}
+
+var _ = Describe("Stream", func() {
+	It("maps and filters", func() {
+		result := run(Range(0, 10).Map(double).Filter(func(elem interface{}) bool {
+			return elem.(int)%4 == 0
+		}).To(Seq()))
+		Expect(result.Err).NotTo(HaveOccurred())
+		Expect(result.Value).To(Equal([]interface{}{0, 4, 8, 12, 16}))
+	})
+
+	It("composes flows", func() {
+		flow := Map(double).Via(Map(double))
+		result := run(FromSlice(1, 2, 3).Via(flow).To(Fold(0, func(acc interface{}, elem interface{}) interface{} {
+			return acc.(int) + elem.(int)
+		})))
+		Expect(result.Value).To(Equal(24))
+	})
+
+	It("handles long streams", func() {
+		result := run(Range(0, 1000).To(Seq()))
+		Expect(result.Value).To(Equal(ints(0, 1000)))
+	})
+
+	It("handles empty streams", func() {
+		result := run(FromSlice().Map(double).To(Seq()))
+		Expect(result.Err).NotTo(HaveOccurred())
+		Expect(result.Value).To(BeNil())
+	})
+
+	It("groups elements", func() {
+		result := run(Range(0, 5).Grouped(2).To(Seq()))
+		Expect(result.Value).To(Equal([]interface{}{
+			[]interface{}{0, 1}, []interface{}{2, 3}, []interface{}{4},
+		}))
+	})
+
+	It("maps asynchronously preserving order", func() {
+		var running, maxRunning int32
+		result := run(Range(0, 20).MapAsync(4, func(elem interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Duration(20-elem.(int)) * time.Millisecond / 10)
+			atomic.AddInt32(&running, -1)
+			return elem, nil
+		}).To(Seq()))
+		Expect(result.Value).To(Equal(ints(0, 20)))
+		Expect(maxRunning).To(BeNumerically("<=", 4))
+	})
+
+	It("throttles elements", func() {
+		clock := tractor.NewManualClock(time.Now())
+		done := make(chan Result, 1)
+		go func() {
+			done <- run(Range(0, 6).Throttle(2, 20*time.Millisecond).To(Seq()), tractor.WithClock(clock))
+		}()
+		for i := 0; i < 2; i++ {
+			Eventually(clock.PendingTimers).Should(Equal(1))
+			Expect(done).NotTo(Receive())
+			clock.Advance(20 * time.Millisecond)
+		}
+		var result Result
+		Eventually(done).Should(Receive(&result))
+		Expect(result.Value).To(Equal(ints(0, 6)))
+		Expect(clock.PendingTimers()).To(Equal(0))
+	})
+
+	It("merges sources", func() {
+		result := run(Merge(Range(0, 50), Range(50, 100), FromSlice()).To(Seq()))
+		Expect(result.Value).To(ConsistOf(ints(0, 100)...))
+	})
+
+	It("zips sources", func() {
+		result := run(Zip(Range(0, 100), FromSlice("a", "b")).To(Seq()))
+		Expect(result.Value).To(Equal([]interface{}{
+			[]interface{}{0, "a"}, []interface{}{1, "b"},
+		}))
+	})
+
+	It("broadcasts to all sinks", func() {
+		result := run(Range(0, 100).To(Broadcast(Seq(), Map(double).To(Fold(0, func(acc interface{}, elem interface{}) interface{} {
+			return acc.(int) + elem.(int)
+		})))))
+		Expect(result.Value).To(Equal([]interface{}{ints(0, 100), 9900}))
+	})
+
+	It("backpressures fast sources", func() {
+		var produced int32
+		source := FromIterator(func() func() (interface{}, bool) {
+			return func() (interface{}, bool) {
+				return int(atomic.AddInt32(&produced, 1)), true
+			}
+		})
+		consumed := 0
+		result := run(source.Map(double).To(ForEach(func(elem interface{}) {
+			consumed++
+			time.Sleep(time.Millisecond)
+			if consumed == 50 {
+				Expect(atomic.LoadInt32(&produced)).To(BeNumerically("<=", 50+3*defaultBufferSize))
+				panic(errors.New("enough"))
+			}
+		})))
+		Expect(result.Err).To(MatchError("enough"))
+	})
+
+	It("fails the stream on panic", func() {
+		result := run(Range(0, 100).Map(func(elem interface{}) interface{} {
+			if elem == 42 {
+				panic("boom")
+			}
+			return elem
+		}).To(Ignore()))
+		Expect(result.Err).To(MatchError("boom"))
+	})
+
+	It("fails the stream on async error", func() {
+		result := run(Range(0, 100).MapAsync(2, func(elem interface{}) (interface{}, error) {
+			if elem == 42 {
+				return nil, errors.New("boom")
+			}
+			return elem, nil
+		}).To(Ignore()))
+		Expect(result.Err).To(MatchError("boom"))
+	})
+})
@@ -0,0 +1,226 @@
+package tractor
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+type SupervisorDecision int
+
+const (
+	// SupervisorRestart runs the setup handler again, keeping the mailbox. Children are kept unless
+	// StopChildren is set.
+	SupervisorRestart SupervisorDecision = iota
+	// SupervisorResume keeps the current handler and drops the failed message.
+	SupervisorResume
+	// SupervisorStop stops the actor.
+	SupervisorStop
+)
+
+type SupervisorStrategy struct {
+	// Decide maps a panic value to a decision. Every failure is restarted when nil.
+	Decide func(err interface{}) SupervisorDecision
+	// MaxRestarts stops the actor after the given number of restarts. Zero allows unlimited restarts.
+	MaxRestarts int
+	// MinBackoff delays the first restart, every following restart doubles the delay up to MaxBackoff.
+	// Messages received while waiting are stashed up to the mailbox capacity, further messages are published as
+	// dead letters. Zero restarts immediately.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// StopChildren stops the children before a restart. They terminate asynchronously, so the new setup handler
+	// may run before they did.
+	StopChildren bool
+}
+
+func (s SupervisorStrategy) decide(err interface{}) SupervisorDecision {
+	if s.Decide == nil {
+		return SupervisorRestart
+	}
+	return s.Decide(err)
+}
+
+func (s SupervisorStrategy) backoff(restarts int) time.Duration {
+	backoff := s.MinBackoff
+	for i := 1; i < restarts && (s.MaxBackoff == 0 || backoff < s.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if s.MaxBackoff > 0 && backoff > s.MaxBackoff {
+		backoff = s.MaxBackoff
+	}
+	return backoff
+}
+
+type restartBackoff struct {
+	restart int
+}
+
+type supervisor struct {
+	ctx        ActorContext
+	setup      SetupHandler
+	strategy   SupervisorStrategy
+	handler    MessageHandler
+	restarts   int
+	restarting bool
+	stash      StashBuffer
+	// inRestart is set while the setup handler and PostRestartSignal run during a restart
+	inRestart bool
+	// stack of the last failure
+	stack []byte
+	// cause of the pending restart
+	cause TerminationCause
+}
+
+// stopCauser is implemented by contexts that record why the actor stopped.
+type stopCauser interface {
+	setStopCause(cause TerminationCause)
+}
+
+// signalContext is implemented by contexts that tell whether signals are delivered.
+type signalContext interface {
+	signalsEnabled() bool
+}
+
+// Supervise applies the strategy when the actor panics instead of stopping it.
+func Supervise(setup SetupHandler, strategy SupervisorStrategy) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		stash := ctx.NewStash(stashCapacity(ctx), OnStashOverflow(StashOverflowDropNew))
+		s := &supervisor{ctx: ctx, setup: setup, strategy: strategy, stash: stash}
+		if next := s.start(); next != nil {
+			return next
+		}
+		return s.handle
+	}
+}
+
+// stashCapacity returns the mailbox capacity of the actor, zero if it is unbounded.
+func stashCapacity(ctx ActorContext) int {
+	if local, ok := ctx.(*localActorContext); ok {
+		return int(local.mailbox.capacity)
+	}
+	return defaultMailboxSize
+}
+
+// start runs the setup handler, it returns a non nil handler if the actor should stop.
+func (s *supervisor) start() MessageHandler {
+	var err interface{}
+	handler := s.safely(&err, func() MessageHandler { return s.setup(s.ctx) })
+	if err != nil {
+		return s.failed(err)
+	}
+	if handler == nil || isStopped(handler) {
+		return Stopped()
+	}
+	s.handler = handler
+	return nil
+}
+
+// restart runs the setup handler again and delivers PostRestartSignal. It returns a non nil handler if the actor
+// should stop.
+func (s *supervisor) restart() MessageHandler {
+	s.inRestart = true
+	defer func() { s.inRestart = false }()
+	s.handler = nil
+	if next := s.start(); next != nil || s.restarting {
+		return next
+	}
+	if s.signals() {
+		if next := s.handle(PostRestartSignal{Cause: s.cause}); isStopped(next) {
+			return next
+		}
+	}
+	return nil
+}
+
+func (s *supervisor) signals() bool {
+	c, ok := s.ctx.(signalContext)
+	return ok && c.signalsEnabled()
+}
+
+func (s *supervisor) handle(msg interface{}) MessageHandler {
+	if s.restarting {
+		switch m := msg.(type) {
+		case restartBackoff:
+			if m.restart == s.restarts {
+				s.restarting = false
+				if next := s.restart(); next != nil {
+					return next
+				}
+				if s.restarting {
+					return nil
+				}
+				return s.stash.UnstashAll(s.handle)
+			}
+		default:
+			if !isSignal(msg) {
+				s.stash.Stash(msg)
+			}
+		}
+		return nil
+	}
+
+	var err interface{}
+	next := s.safely(&err, func() MessageHandler { return s.handler(msg) })
+	if err != nil {
+		return s.failed(err)
+	}
+	if isSpecial(next) {
+		return next
+	}
+	s.handler = next
+	return nil
+}
+
+func (s *supervisor) safely(err *interface{}, f func() MessageHandler) MessageHandler {
+	defer func() {
+		if e := recover(); e != nil {
+			*err = e
+			s.stack = debug.Stack()
+		}
+	}()
+	return f()
+}
+
+// failed applies the strategy to the failure. It returns a non nil handler if the actor should stop.
+func (s *supervisor) failed(err interface{}) MessageHandler {
+	_, _ = fmt.Fprintf(os.Stderr, "actor panic: %s\n", err)
+	switch s.strategy.decide(err) {
+	case SupervisorResume:
+		if s.handler == nil {
+			return s.stop(err)
+		}
+		return nil
+	case SupervisorStop:
+		return s.stop(err)
+	}
+
+	if s.strategy.MaxRestarts > 0 && s.restarts >= s.strategy.MaxRestarts {
+		return s.stop(err)
+	}
+	s.restarts++
+	s.cause = TerminationCause{Reason: StopFailed, Panic: err, Stack: s.stack}
+	if s.signals() && s.handler != nil {
+		var ignored interface{}
+		s.safely(&ignored, func() MessageHandler { return s.handler(PreRestartSignal{Cause: s.cause}) })
+	}
+	if s.strategy.StopChildren {
+		for _, child := range s.ctx.Children() {
+			s.ctx.Stop(child)
+		}
+	}
+	// a failing restart is retried through the mailbox instead of recursing
+	if s.strategy.MinBackoff <= 0 && !s.inRestart {
+		return s.restart()
+	}
+	s.restarting = true
+	scheduleOnce(s.ctx, s.strategy.backoff(s.restarts), restartBackoff{restart: s.restarts})
+	return nil
+}
+
+func (s *supervisor) stop(err interface{}) MessageHandler {
+	if c, ok := s.ctx.(stopCauser); ok {
+		c.setStopCause(TerminationCause{Reason: StopSupervisor, Panic: err, Stack: s.stack})
+	}
+	return Stopped()
+}
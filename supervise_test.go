@@ -0,0 +1,214 @@
+package tractor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failing counts messages and panics on "fail".
+func failing(setups *int, received *[]interface{}) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		*setups++
+		count := 0
+		return func(msg interface{}) MessageHandler {
+			switch msg {
+			case "fail":
+				panic(errors.New("failed"))
+			case "stop":
+				return Stopped()
+			}
+			count++
+			*received = append(*received, count)
+			return nil
+		}
+	}
+}
+
+var _ = Describe("Supervise", func() {
+	It("restarts the actor", func() {
+		setups := 0
+		var received []interface{}
+		runActor(Supervise(failing(&setups, &received), SupervisorStrategy{}), "a", "b", "fail", "c", "stop")
+		Expect(setups).To(Equal(2))
+		Expect(received).To(Equal([]interface{}{1, 2, 1}))
+	})
+
+	It("resumes the actor", func() {
+		setups := 0
+		var received []interface{}
+		strategy := SupervisorStrategy{Decide: func(err interface{}) SupervisorDecision {
+			return SupervisorResume
+		}}
+		runActor(Supervise(failing(&setups, &received), strategy), "a", "fail", "b", "stop")
+		Expect(setups).To(Equal(1))
+		Expect(received).To(Equal([]interface{}{1, 2}))
+	})
+
+	It("stops the actor", func() {
+		setups := 0
+		var received []interface{}
+		strategy := SupervisorStrategy{Decide: func(err interface{}) SupervisorDecision {
+			return SupervisorStop
+		}}
+		runActor(Supervise(failing(&setups, &received), strategy), "a", "fail", "b")
+		Expect(received).To(Equal([]interface{}{1}))
+	})
+
+	It("stops after max restarts", func() {
+		setups := 0
+		var received []interface{}
+		runActor(Supervise(failing(&setups, &received), SupervisorStrategy{MaxRestarts: 2}), "fail", "a", "fail", "fail", "b")
+		Expect(setups).To(Equal(3))
+		Expect(received).To(Equal([]interface{}{1}))
+	})
+
+	It("restarts with backoff stashing messages", func() {
+		setups := 0
+		var received []interface{}
+		start := time.Now()
+		strategy := SupervisorStrategy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}
+		runActor(Supervise(failing(&setups, &received), strategy), "fail", "a", "fail", "b", "c", "stop")
+		Expect(time.Since(start)).To(BeNumerically(">=", 25*time.Millisecond))
+		Expect(setups).To(Equal(3))
+		Expect(received).To(Equal([]interface{}{1, 1, 2}))
+	})
+
+	It("publishes messages beyond the mailbox capacity as dead letters during the backoff", func() {
+		setups := 0
+		var received []interface{}
+		clock := NewManualClock(time.Now())
+		system := Start(Supervise(failing(&setups, &received), SupervisorStrategy{MinBackoff: time.Second}), WithClock(clock))
+		events, ch := ToChannel(system.Context(), 10)
+		system.Context().EventStream().Subscribe(events, DeadLetter{})
+		system.Root().Tell(system.Context(), "fail")
+		for i := 0; i <= defaultMailboxSize; i++ {
+			system.Root().Tell(system.Context(), i)
+		}
+		Eventually(ch).Should(Receive(Equal(DeadLetter{Message: defaultMailboxSize, Sender: system.Context().Self(), Recipient: system.Root()})))
+
+		clock.Advance(time.Second)
+		system.Root().Tell(system.Context(), "stop")
+		system.Wait()
+		Expect(received).To(HaveLen(defaultMailboxSize))
+	})
+
+	It("stops when the setup always panics", func() {
+		setups := 0
+		system := Start(Supervise(func(ctx ActorContext) MessageHandler {
+			setups++
+			panic("setup failed")
+		}, SupervisorStrategy{MaxRestarts: 3}))
+		probe := NewTestProbe(GinkgoT(), system)
+		probe.Watch(system.Root())
+		terminated := ExpectMessageType[Terminated](probe, time.Second)
+		Expect(terminated.Cause.Reason).To(Equal(StopSupervisor))
+		Expect(terminated.Cause.Panic).To(Equal("setup failed"))
+		system.Wait()
+		Expect(setups).To(Equal(4))
+	})
+
+	It("restarts again when the restart fails", func() {
+		setups := 0
+		var received []interface{}
+		runActor(Supervise(func(ctx ActorContext) MessageHandler {
+			setups++
+			if setups == 2 {
+				panic("setup failed")
+			}
+			return func(msg interface{}) MessageHandler {
+				if msg == "fail" {
+					panic("failed")
+				}
+				received = append(received, msg)
+				return Stopped()
+			}
+		}, SupervisorStrategy{}), "fail", "done")
+		Expect(setups).To(Equal(3))
+		Expect(received).To(Equal([]interface{}{"done"}))
+	})
+
+	It("retries a failing setup with backoff", func() {
+		setups := 0
+		runActor(Supervise(func(ctx ActorContext) MessageHandler {
+			setups++
+			panic("setup failed")
+		}, SupervisorStrategy{MaxRestarts: 3, MinBackoff: time.Millisecond}))
+		Expect(setups).To(Equal(4))
+	})
+
+	It("delivers restart signals", func() {
+		var received []interface{}
+		runActor(Supervise(func(ctx ActorContext) MessageHandler {
+			ctx.DeliverSignals(true)
+			received = append(received, "setup")
+			return func(msg interface{}) MessageHandler {
+				switch msg := msg.(type) {
+				case PreRestartSignal:
+					received = append(received, msg.Cause.Panic)
+				case PostRestartSignal:
+					received = append(received, msg.Cause.Reason)
+				case string:
+					if msg == "fail" {
+						panic("failed")
+					}
+					return Stopped()
+				default:
+					received = append(received, msg)
+				}
+				return nil
+			}
+		}, SupervisorStrategy{}), "fail", "stop")
+		Expect(received).To(Equal([]interface{}{
+			"setup", PostInitSignal{}, "failed", "setup", StopFailed, PreStopSignal{}, PostStopSignal{},
+		}))
+	})
+
+	It("keeps or stops children across restarts", func() {
+		for _, stopChildren := range []bool{false, true} {
+			stopped := make(chan TerminationCause, 1)
+			children := make(chan int, 1)
+			system := Start(Supervise(func(ctx ActorContext) MessageHandler {
+				if len(ctx.Children()) == 0 {
+					ctx.Spawn(func(ctx ActorContext) MessageHandler {
+						ctx.DeliverSignals(true)
+						return func(msg interface{}) MessageHandler {
+							if signal, ok := msg.(PostStopSignal); ok {
+								stopped <- signal.Cause
+							}
+							return nil
+						}
+					})
+				}
+				return func(msg interface{}) MessageHandler {
+					switch msg {
+					case "fail":
+						panic("failed")
+					case "children":
+						children <- len(ctx.Children())
+					}
+					return nil
+				}
+			}, SupervisorStrategy{StopChildren: stopChildren}))
+			system.Root().Tell(system.Context(), "fail")
+			if stopChildren {
+				Eventually(stopped).Should(Receive(Equal(TerminationCause{Reason: StopNormal})))
+			} else {
+				system.Root().Tell(system.Context(), "children")
+				Eventually(children).Should(Receive(Equal(1)))
+				Consistently(stopped, 10*time.Millisecond).ShouldNot(Receive())
+			}
+			Expect(system.Terminate(context.Background())).To(Succeed())
+		}
+	})
+
+	It("computes backoff", func() {
+		strategy := SupervisorStrategy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+		Expect(strategy.backoff(1)).To(Equal(time.Second))
+		Expect(strategy.backoff(3)).To(Equal(4 * time.Second))
+		Expect(strategy.backoff(4)).To(Equal(5 * time.Second))
+	})
+})
@@ -1,23 +1,69 @@
 package tractor
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
 const defaultMailboxSize = 1000
-const defaultCommandsSize = 2
 
-func Start(root SetupHandler) ActorSystem {
-	system := &actorSystemImpl{}
+func Start(root SetupHandler, options ...SystemOption) ActorSystem {
+	system := &actorSystemImpl{clock: RealClock(), dispatcher: dedicatedDispatcher{}}
+	for _, option := range options {
+		option(system)
+	}
 	system.start(root)
 	return system
 }
 
+// SystemOption configures the actor system.
+type SystemOption func(system *actorSystemImpl)
+
+// PublishUnhandledAsDeadLetters additionally publishes a DeadLetter for every unhandled message.
+func PublishUnhandledAsDeadLetters() SystemOption {
+	return func(system *actorSystemImpl) {
+		system.unhandledAsDeadLetters = true
+	}
+}
+
+// WithClock makes the system use the clock for all scheduled messages.
+func WithClock(clock Clock) SystemOption {
+	return func(system *actorSystemImpl) {
+		system.clock = clock
+	}
+}
+
+// WarnSlowHandlers publishes SlowHandler when an actor on the system dispatcher takes longer than the threshold
+// to handle a message. Blocking actors should run on a pinned or blocking IO dispatcher instead.
+func WarnSlowHandlers(threshold time.Duration) SystemOption {
+	return func(system *actorSystemImpl) {
+		system.slowHandlerThreshold = threshold
+	}
+}
+
+// WithDispatcher runs all actors on the dispatcher.
+func WithDispatcher(dispatcher Dispatcher) SystemOption {
+	return func(system *actorSystemImpl) {
+		system.dispatcher = dispatcher
+	}
+}
+
 type actorSystemImpl struct {
-	context *localActorContext
-	root    *localActorRef
+	context                *localActorContext
+	root                   *localActorRef
+	eventStream            *eventStream
+	unhandledAsDeadLetters bool
+	clock                  Clock
+	dispatcher             Dispatcher
+	slowHandlerThreshold   time.Duration
+	shutdown               *CoordinatedShutdown
+	handleSignals          bool
+	// terminated is closed when all actors terminated
+	terminated chan struct{}
 }
 
 func (system *actorSystemImpl) Context() ActorContext {
@@ -25,7 +71,20 @@ func (system *actorSystemImpl) Context() ActorContext {
 }
 
 func (system *actorSystemImpl) Wait() {
-	system.context.childrenWaitGroup.Wait()
+	if d, ok := system.dispatcher.(*DeterministicDispatcher); ok {
+		d.runUntil(system.terminated)
+		return
+	}
+	<-system.terminated
+}
+
+// Terminate runs the coordinated shutdown, which stops all actors.
+func (system *actorSystemImpl) Terminate(ctx context.Context) error {
+	return system.shutdown.Run(ctx)
+}
+
+func (system *actorSystemImpl) CoordinatedShutdown() *CoordinatedShutdown {
+	return system.shutdown
 }
 
 type localActorRef struct {
@@ -33,7 +92,7 @@ type localActorRef struct {
 }
 
 func (ref *localActorRef) Tell(ctx ActorContext, msg interface{}) {
-	ref.context.mailbox.Tell(envelope{msg: msg, sender: ctx.Self()})
+	ref.context.tellFrom(ctx, envelope{msg: msg, sender: ctx.Self()})
 }
 
 type terminateListener struct {
@@ -46,72 +105,156 @@ type envelope struct {
 	msg    interface{}
 }
 
-type mailbox struct {
-	messages chan envelope
-	commands chan interface{}
-	stash    []envelope
-}
-
-func (m *mailbox) takeCommand() interface{} {
-	select {
-	case cmd := <-m.commands:
-		return cmd
-	default:
-		return nil
+type localActorContext struct {
+	system            *actorSystemImpl
+	parent            *localActorContext
+	childrenWaitGroup *sync.WaitGroup
+	self              *localActorRef
+	deliverSignals    bool
+	// childrenMu guards changes of children, the system context is shared by all goroutines
+	childrenMu sync.Mutex
+	children   []*localActorRef
+	listeners  []terminateListener
+	// watching are the actors watched by this one, only accessed while it runs
+	watching        map[*localActorRef]bool
+	currentEnvelope *envelope
+	// current holds the envelope pointed to by currentEnvelope to avoid allocating it for every message
+	current envelope
+	// batchSize is the maximum number of messages delivered as a Batch
+	batchSize  int
+	mailbox    *mailbox
+	dispatcher Dispatcher
+	// wake is used by the dedicated dispatcher
+	wake chan struct{}
+	// done is closed when the actor terminates
+	done chan struct{}
+
+	// state of the actor, only accessed while it runs
+	setupHandler SetupHandler
+	handler      MessageHandler
+	started      bool
+	// stopSelf is set by Stop(Self()) to stop once the handler returns
+	stopSelf   bool
+	stopping   bool
+	terminated bool
+	// cause is the reason the actor stopped
+	cause TerminationCause
+}
+
+// tell adds the message to the mailbox waiting while it is full. Messages to terminated actors are published
+// as dead letters.
+func (ctx *localActorContext) tell(e envelope, abort <-chan struct{}) bool {
+	return ctx.push(e, true, abort)
+}
+
+// tellFrom adds the message of the sender to the mailbox, waiting while it is full if the sender may.
+func (ctx *localActorContext) tellFrom(sender ActorContext, e envelope) bool {
+	return ctx.push(e, waitsForSpace(sender), nil)
+}
+
+// waitsForSpace returns false for actors on a PoolDispatcher, waiting for space in a full mailbox would hold on to
+// a goroutine of the pool that the receiver might need to make space.
+func waitsForSpace(sender ActorContext) bool {
+	if s, ok := sender.(senderContext); ok {
+		sender = s.ActorContext
 	}
+	if local, ok := sender.(*localActorContext); ok {
+		_, pool := local.dispatcher.(*PoolDispatcher)
+		return !pool
+	}
+	return true
 }
 
-func (m *mailbox) TellCommand(msg interface{}) {
-	m.commands <- msg
-}
-
-func (m *mailbox) take() interface{} {
-	if len(m.stash) > 0 {
-		env := m.stash[0]
-		m.stash = m.stash[1:]
-		return env
+func (ctx *localActorContext) push(e envelope, wait bool, abort <-chan struct{}) bool {
+	ok, schedule := ctx.mailbox.push(e, wait, abort)
+	if schedule {
+		ctx.dispatcher.schedule(ctx)
 	}
-	select {
-	case cmd := <-m.commands:
-		return cmd
-	case env := <-m.messages:
-		return env
+	if !ok && ctx.mailbox.isClosed() {
+		ctx.deadLetter(e)
 	}
+	return ok
 }
 
-func (m *mailbox) Tell(e envelope) {
-	m.messages <- e
-}
-
-func (m *mailbox) unstashAll(buffer []envelope) {
-	m.stash = append(m.stash, buffer...)
-}
-
-type localActorContext struct {
-	system            *actorSystemImpl
-	parent            *localActorContext
-	childrenWaitGroup *sync.WaitGroup
-	self              *localActorRef
-	deliverSignals    bool
-	children          []*localActorRef
-	listeners         []terminateListener
-	currentEnvelope   *envelope
-	mailbox           mailbox
+func (ctx *localActorContext) tellCommand(cmd interface{}) bool {
+	ok, schedule := ctx.mailbox.pushCommand(cmd)
+	if schedule {
+		ctx.dispatcher.schedule(ctx)
+	}
+	return ok
 }
 
 func (ctx *localActorContext) Ask(ref ActorRef, msg interface{}) chan interface{} {
 	ch := make(chan interface{}, 1)
+	// the reply doesn't need a goroutine of a pool the asking handler might be blocking
+	var options []SpawnOption
+	if _, ok := ctx.system.dispatcher.(*DeterministicDispatcher); !ok {
+		options = append(options, OnDispatcher(dedicatedDispatcher{}))
+	}
 	ctx.Spawn(func(ctx ActorContext) MessageHandler {
 		ref.Tell(ctx, msg)
 		return func(message interface{}) MessageHandler {
 			ch <- message
 			return Stopped()
 		}
-	})
+	}, options...)
 	return ch
 }
 
+// senderContext overrides Self() so that a message can be told on behalf of a different sender.
+type senderContext struct {
+	ActorContext
+	sender ActorRef
+}
+
+func (ctx senderContext) Self() ActorRef {
+	return ctx.sender
+}
+
+// forward tells the message to the ref preserving the sender of the current message.
+func forward(ctx ActorContext, ref ActorRef, msg interface{}) {
+	forwardFrom(ctx, ctx.Sender(), ref, msg)
+}
+
+func forwardFrom(ctx ActorContext, sender ActorRef, ref ActorRef, msg interface{}) {
+	ref.Tell(senderContext{ActorContext: ctx, sender: sender}, msg)
+}
+
+// scheduler is implemented by contexts that control how messages are scheduled.
+type scheduler interface {
+	scheduleOnce(delay time.Duration, msg interface{}) Cancellable
+}
+
+// scheduleOnce tells msg to the actor itself after the delay.
+func scheduleOnce(ctx ActorContext, delay time.Duration, msg interface{}) Cancellable {
+	if s, ok := ctx.(scheduler); ok {
+		return s.scheduleOnce(delay, msg)
+	}
+	self := ctx.Self()
+	return time.AfterFunc(delay, func() {
+		self.Tell(ctx, msg)
+	})
+}
+
+// now returns the time of the system clock.
+func now(ctx ActorContext) time.Time {
+	if local, ok := ctx.(*localActorContext); ok {
+		return local.system.clock.Now()
+	}
+	return time.Now()
+}
+
+func (ctx *localActorContext) scheduleOnce(delay time.Duration, msg interface{}) Cancellable {
+	self := ctx.self
+	return ctx.system.clock.AfterFunc(delay, func() {
+		self.Tell(ctx, msg)
+	})
+}
+
 func (ctx *localActorContext) Sender() ActorRef {
+	if ctx.currentEnvelope == nil {
+		return nil
+	}
 	return ctx.currentEnvelope.sender
 }
 
@@ -119,11 +262,68 @@ func (ctx *localActorContext) Watch(actor ActorRef) {
 	ctx.WatchWith(actor, Terminated{})
 }
 
+// WatchWith delivers msg when the actor terminates, immediately if it already did. Watching the same actor again
+// replaces the message.
 func (ctx *localActorContext) WatchWith(actor ActorRef, msg interface{}) {
-	actor.(*localActorRef).context.mailbox.TellCommand(&listenCommand{ref: ctx.self, msg: msg})
+	watched := actor.(*localActorRef)
+	if ctx.watching == nil {
+		ctx.watching = map[*localActorRef]bool{}
+	}
+	ctx.watching[watched] = true
+	if !watched.context.tellCommand(&listenCommand{ref: ctx.self, msg: msg}) {
+		// the failed command guarantees that the terminated context and its cause are no longer modified
+		msg = watched.context.terminatedMessage(msg)
+		// the actor can't wait for space in its own mailbox
+		ctx.notify(envelope{sender: watched, msg: &watchNotification{ref: watched, msg: msg}}, false)
+	}
+}
+
+// Unwatch stops watching the actor. Its termination message isn't delivered even if it is already queued.
+func (ctx *localActorContext) Unwatch(actor ActorRef) {
+	watched := actor.(*localActorRef)
+	if !ctx.watching[watched] {
+		return
+	}
+	delete(ctx.watching, watched)
+	watched.context.tellCommand(&unlistenCommand{ref: ctx.self})
+}
+
+// notify adds the envelope to the mailbox unless the actor terminated.
+func (ctx *localActorContext) notify(e envelope, wait bool) {
+	if _, schedule := ctx.mailbox.push(e, wait, ctx.done); schedule {
+		ctx.dispatcher.schedule(ctx)
+	}
+}
+
+// unwrap converts adapted messages and returns the message of a watch notification if the actor still watches its
+// sender. It returns false if there is nothing to deliver.
+func (ctx *localActorContext) unwrap(e envelope) (envelope, bool) {
+	if adapted, ok := e.msg.(*adaptedMessage); ok {
+		e.msg = ctx.adaptMessage(e, adapted)
+		return e, e.msg != nil
+	}
+	notification, ok := e.msg.(*watchNotification)
+	if !ok {
+		return e, true
+	}
+	if !ctx.watching[notification.ref] {
+		return e, false
+	}
+	delete(ctx.watching, notification.ref)
+	return envelope{sender: e.sender, msg: notification.msg}, true
+}
+
+func (ctx *localActorContext) EventStream() EventStream {
+	return ctx.system.eventStream
+}
+
+func (ctx *localActorContext) CoordinatedShutdown() *CoordinatedShutdown {
+	return ctx.system.shutdown
 }
 
 func (ctx *localActorContext) Children() []ActorRef {
+	ctx.childrenMu.Lock()
+	defer ctx.childrenMu.Unlock()
 	result := make([]ActorRef, len(ctx.children))
 	for i, ref := range ctx.children {
 		result[i] = ref
@@ -131,20 +331,20 @@ func (ctx *localActorContext) Children() []ActorRef {
 	return result
 }
 
-func newContext(system *actorSystemImpl, self *localActorRef, parent *localActorContext) *localActorContext {
+func newContext(
+	system *actorSystemImpl,
+	self *localActorRef,
+	parent *localActorContext,
+	dispatcher Dispatcher,
+) *localActorContext {
 	return &localActorContext{
 		system:            system,
 		self:              self,
 		parent:            parent,
 		childrenWaitGroup: &sync.WaitGroup{},
-		mailbox:           newMailbox(),
-	}
-}
-
-func newMailbox() mailbox {
-	return mailbox{
-		messages: make(chan envelope, defaultMailboxSize),
-		commands: make(chan interface{}, defaultCommandsSize),
+		dispatcher:        dispatcher,
+		mailbox:           newMailbox(dispatcher.mailboxCapacity()),
+		done:              make(chan struct{}),
 	}
 }
 
@@ -160,118 +360,232 @@ func (ctx *localActorContext) DeliverSignals(value bool) {
 	ctx.deliverSignals = value
 }
 
-func (ctx *localActorContext) Spawn(handler SetupHandler) ActorRef {
-	return ctx.spawn(handler)
+func (ctx *localActorContext) signalsEnabled() bool {
+	return ctx.deliverSignals
+}
+
+func (ctx *localActorContext) Spawn(handler SetupHandler, options ...SpawnOption) ActorRef {
+	return ctx.spawn(handler, options...)
 }
 
-func (ctx *localActorContext) spawn(handler SetupHandler) *localActorRef {
+func (ctx *localActorContext) spawn(handler SetupHandler, options ...SpawnOption) *localActorRef {
+	settings := spawnSettings{dispatcher: ctx.system.dispatcher}
+	for _, option := range options {
+		option(&settings)
+	}
 	ref := &localActorRef{}
-	childContext := newContext(ctx.system, ref, ctx)
+	childContext := newContext(ctx.system, ref, ctx, settings.dispatcher)
+	childContext.batchSize = settings.batchSize
+	childContext.setupHandler = handler
 	ref.context = childContext
+	ctx.childrenMu.Lock()
 	ctx.children = append(ctx.children, ref)
+	ctx.childrenMu.Unlock()
 	ctx.childrenWaitGroup.Add(1)
-	go func() {
-		childContext.mainLoop(handler)
-	}()
+	childContext.dispatcher.attach(childContext)
+	childContext.dispatcher.schedule(childContext)
 	return ref
 }
 
-type terminateCommand struct{}
+type terminateCommand struct {
+	reason StopReason
+}
 
 type listenCommand struct {
 	ref ActorRef
 	msg interface{}
 }
-type childTerminatedCommand struct {
+type unlistenCommand struct {
 	ref ActorRef
 }
 
-func (ctx *localActorContext) mainLoop(setup SetupHandler) {
-	messageHandler := ctx.setup(setup)
-	if messageHandler == nil {
-		messageHandler = Stopped()
-	}
+// watchNotification is queued as a message to keep it after the messages of the terminated actor.
+type watchNotification struct {
+	ref *localActorRef
+	msg interface{}
+}
+type childTerminatedCommand struct {
+	ref   ActorRef
+	cause TerminationCause
+}
 
-	lastMessageHandler := messageHandler
-	if ctx.deliverSignals && !isStopped(messageHandler) {
-		if newHandler := ctx.deliver(messageHandler, PostInitSignal{}); newHandler != nil {
-			messageHandler = newHandler
-		}
+// run processes up to throughput commands and messages, zero means until the mailbox is empty. The actor is
+// scheduled again if there is more work.
+func (ctx *localActorContext) run(throughput int) {
+	if !ctx.started {
+		ctx.started = true
+		ctx.start()
 	}
-
-	for {
-		if isStopped(messageHandler) {
-			break
+	for n := 0; throughput <= 0 || n < throughput; n++ {
+		if ctx.terminated {
+			return
 		}
-
-		lastMessageHandler = messageHandler
-
-		msg := ctx.mailbox.take()
-
-		switch command := msg.(type) {
-		case envelope:
-			ctx.currentEnvelope = &command
-			if newHandler := ctx.deliver(messageHandler, command.msg); newHandler != nil {
-				messageHandler = newHandler
+		item, ok := ctx.mailbox.take()
+		if !ok {
+			if ctx.mailbox.idle() {
+				return
 			}
-			ctx.currentEnvelope = nil
-		case *terminateCommand:
-			messageHandler = Stopped()
-		case *listenCommand:
-			ctx.onListenCommand(command)
-		case *childTerminatedCommand:
-			ctx.onChildTerminatedCommand(command)
-		default:
-			panic(fmt.Sprintf("Bad command: %T", command))
+			continue
 		}
+		ctx.process(item)
+	}
+	if !ctx.terminated && !ctx.mailbox.idle() {
+		ctx.dispatcher.schedule(ctx)
 	}
+}
 
-	// drain commands if any
-l:
-	for {
-		cmd := ctx.mailbox.takeCommand()
-		if cmd == nil {
-			break l
-		}
+func (ctx *localActorContext) start() {
+	handler := ctx.setup(ctx.setupHandler)
+	ctx.setupHandler = nil
+	if handler == nil || isStopped(handler) {
+		ctx.stop()
+		return
+	}
+	ctx.handler = handler
+	if ctx.stopSelf {
+		ctx.stop()
+		return
+	}
+	if ctx.deliverSignals {
+		ctx.become(ctx.deliver(ctx.handler, PostInitSignal{}))
+	}
+}
 
-		switch command := cmd.(type) {
-		case *terminateCommand:
-			// do nothing
-		case *listenCommand:
-			ctx.onListenCommand(command)
-		case *childTerminatedCommand:
-			ctx.onChildTerminatedCommand(command)
-		default:
-			panic(fmt.Sprintf("Bad command: %T", cmd))
+func (ctx *localActorContext) process(item interface{}) {
+	switch command := item.(type) {
+	case envelope:
+		if ctx.stopping {
+			return
+		}
+		command, ok := ctx.unwrap(command)
+		if !ok {
+			return
 		}
+		if _, ok := command.msg.(PoisonPill); ok {
+			ctx.stop()
+			return
+		}
+		if ctx.batchSize > 0 {
+			ctx.processBatch(command)
+			return
+		}
+		ctx.current = command
+		ctx.currentEnvelope = &ctx.current
+		ctx.become(ctx.deliver(ctx.handler, command.msg))
+		ctx.currentEnvelope = nil
+		ctx.current = envelope{}
+	case *terminateCommand:
+		if !ctx.stopping {
+			ctx.cause = TerminationCause{Reason: command.reason}
+		}
+		ctx.stop()
+	case *listenCommand:
+		ctx.onListenCommand(command)
+	case *unlistenCommand:
+		ctx.onUnlistenCommand(command)
+	case *childTerminatedCommand:
+		ctx.onChildTerminatedCommand(command)
+		if command.cause.Reason == StopFailed && ctx.deliverSignals && !ctx.stopping {
+			ctx.become(ctx.deliver(ctx.handler, ChildFailed{Ref: command.ref, Cause: command.cause}))
+		}
+		if ctx.stopping && len(ctx.children) == 0 {
+			ctx.terminate()
+		}
+	default:
+		panic(fmt.Sprintf("Bad command: %T", command))
 	}
+}
 
-	if ctx.deliverSignals {
-		ctx.deliver(lastMessageHandler, PreStopSignal{})
+func (ctx *localActorContext) become(handler MessageHandler) {
+	switch {
+	case handler == nil:
+	case isStopped(handler):
+		ctx.stop()
+		return
+	default:
+		ctx.handler = handler
+	}
+	if ctx.stopSelf {
+		ctx.stop()
+	}
+}
+
+// stop stops accepting messages and terminates the children. The actor terminates when all children did.
+func (ctx *localActorContext) stop() {
+	if ctx.stopping {
+		return
+	}
+	ctx.stopping = true
+	for _, e := range ctx.mailbox.close() {
+		ctx.deadLetter(e)
 	}
 
+	if ctx.deliverSignals && ctx.handler != nil {
+		ctx.deliver(ctx.handler, PreStopSignal{})
+	}
+	reason := StopParentStopped
+	if ctx.cause.Reason == StopSystemShutdown {
+		reason = StopSystemShutdown
+	}
 	for _, child := range ctx.children {
-		child.context.mailbox.TellCommand(&terminateCommand{})
+		child.context.tellCommand(&terminateCommand{reason: reason})
 	}
-	ctx.childrenWaitGroup.Wait()
+	if len(ctx.children) == 0 {
+		ctx.terminate()
+	}
+}
 
-	if ctx.deliverSignals {
-		ctx.deliver(lastMessageHandler, PostStopSignal{})
+func (ctx *localActorContext) terminate() {
+	if ctx.deliverSignals && ctx.handler != nil {
+		ctx.deliver(ctx.handler, PostStopSignal{Cause: ctx.cause})
 	}
+	ctx.terminated = true
+	for _, command := range ctx.mailbox.terminate() {
+		switch command := command.(type) {
+		case *listenCommand:
+			ctx.onListenCommand(command)
+		case *unlistenCommand:
+			ctx.onUnlistenCommand(command)
+		}
+	}
+
 	ctx.parent.childrenWaitGroup.Done()
 	if ctx.parent.self != nil {
-		ctx.parent.mailbox.TellCommand(&childTerminatedCommand{ref: ctx.self})
+		ctx.parent.tellCommand(&childTerminatedCommand{ref: ctx.self, cause: ctx.cause})
 	}
 
 	for _, listener := range ctx.listeners {
-		listener.ref.Tell(ctx, listener.msg)
+		msg := ctx.terminatedMessage(listener.msg)
+		if watcher, ok := listener.ref.(*localActorRef); ok {
+			watcher.context.notify(envelope{sender: ctx.self, msg: &watchNotification{ref: ctx.self, msg: msg}}, waitsForSpace(ctx))
+		} else {
+			listener.ref.Tell(ctx, msg)
+		}
+	}
+	for watched := range ctx.watching {
+		watched.context.tellCommand(&unlistenCommand{ref: ctx.self})
+	}
+	close(ctx.done)
+}
+
+// terminatedMessage fills in Ref and Cause of Terminated, other watch messages are returned as they are.
+func (ctx *localActorContext) terminatedMessage(msg interface{}) interface{} {
+	terminated, ok := msg.(Terminated)
+	if !ok {
+		return msg
+	}
+	if terminated.Ref == nil {
+		terminated.Ref = ctx.self
 	}
+	terminated.Cause = ctx.cause
+	return terminated
 }
 
 func (ctx *localActorContext) setup(handler SetupHandler) MessageHandler {
 	defer func() {
 		if err := recover(); err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "actor setup panic: %s\n", err)
+			ctx.failed(err)
 		}
 	}()
 	return handler(ctx)
@@ -282,17 +596,84 @@ func (ctx *localActorContext) deliver(messageHandler MessageHandler, msg interfa
 	defer func() {
 		if err := recover(); err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "actor panic: %s\n", err)
+			ctx.failed(err)
 		}
 	}()
+	if threshold := ctx.system.slowHandlerThreshold; threshold > 0 && ctx.dispatcher == ctx.system.dispatcher {
+		start := time.Now()
+		defer func() {
+			if elapsed := time.Since(start); elapsed > threshold {
+				ctx.onSlowHandler(msg, elapsed)
+			}
+		}()
+	}
 	newHandler = messageHandler(msg)
+	if isUnhandled(newHandler) {
+		ctx.onUnhandled(msg)
+		newHandler = nil
+	}
 	return newHandler
 }
 
+// failed records the panic as the termination cause unless the actor is already stopping.
+func (ctx *localActorContext) failed(err interface{}) {
+	if !ctx.stopping {
+		ctx.cause = TerminationCause{Reason: StopFailed, Panic: err, Stack: debug.Stack()}
+	}
+}
+
+// setStopCause is used by behaviors that stop the actor for a specific reason.
+func (ctx *localActorContext) setStopCause(cause TerminationCause) {
+	if !ctx.stopping {
+		ctx.cause = cause
+	}
+}
+
+func (ctx *localActorContext) onUnhandled(msg interface{}) {
+	if isSignal(msg) {
+		return
+	}
+	var sender ActorRef
+	if ctx.currentEnvelope != nil {
+		sender = ctx.currentEnvelope.sender
+	}
+	events := ctx.system.eventStream
+	if events.publish(UnhandledMessage{Message: msg, Sender: sender, Recipient: ctx.self}) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "actor %p: unhandled message %T\n", ctx.self, msg)
+	}
+	if ctx.system.unhandledAsDeadLetters {
+		events.publish(DeadLetter{Message: msg, Sender: sender, Recipient: ctx.self})
+	}
+}
+
+func (ctx *localActorContext) onSlowHandler(msg interface{}, elapsed time.Duration) {
+	if ctx.system.eventStream.publish(SlowHandler{Actor: ctx.self, Message: msg, Duration: elapsed}) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "actor %p: handling %T took %v\n", ctx.self, msg, elapsed)
+	}
+}
+
 func (ctx *localActorContext) onListenCommand(command *listenCommand) {
+	for i, listener := range ctx.listeners {
+		if listener.ref == command.ref {
+			ctx.listeners[i].msg = command.msg
+			return
+		}
+	}
 	ctx.listeners = append(ctx.listeners, terminateListener{ref: command.ref, msg: command.msg})
 }
 
+func (ctx *localActorContext) onUnlistenCommand(command *unlistenCommand) {
+	for i, listener := range ctx.listeners {
+		if listener.ref == command.ref {
+			ctx.listeners = append(ctx.listeners[:i], ctx.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
 func (ctx *localActorContext) onChildTerminatedCommand(command *childTerminatedCommand) {
+	ctx.childrenMu.Lock()
+	defer ctx.childrenMu.Unlock()
 	for i, ref := range ctx.children {
 		if ref == command.ref {
 			ctx.children = append(ctx.children[:i], ctx.children[i+1:]...)
@@ -307,36 +688,16 @@ func (system *actorSystemImpl) Root() ActorRef {
 }
 
 func (system *actorSystemImpl) start(root SetupHandler) {
-	system.context = newContext(system, nil, nil)
+	system.context = newContext(system, nil, nil, system.dispatcher)
+	system.eventStream = newEventStream(system.context)
+	system.shutdown = newCoordinatedShutdown(system)
+	system.terminated = make(chan struct{})
 	system.root = system.context.spawn(root)
-}
-
-type stashBuffer struct {
-	context *localActorContext
-	buffer  []envelope
-}
-
-func (s *stashBuffer) Stash(msg interface{}) {
-	s.buffer = append(s.buffer, envelope{
-		sender: s.context.currentEnvelope.sender,
-		msg:    msg,
-	})
-}
-
-func (s *stashBuffer) UnstashAll(handler MessageHandler) MessageHandler {
-	s.context.mailbox.unstashAll(s.buffer)
-	s.buffer = nil
-	return handler
-}
-
-func (s *stashBuffer) Unstash(handler MessageHandler, count int) MessageHandler {
-	s.context.mailbox.unstashAll(s.buffer[:count])
-	s.buffer = s.buffer[count:]
-	return handler
-}
-
-func (ctx *localActorContext) NewStash(size int) StashBuffer {
-	return &stashBuffer{
-		context: ctx,
+	go func() {
+		system.context.childrenWaitGroup.Wait()
+		close(system.terminated)
+	}()
+	if system.handleSignals {
+		system.watchSignals()
 	}
 }
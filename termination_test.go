@@ -0,0 +1,113 @@
+package tractor
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// crashing panics on "crash" and stops on "stop", reporting signals to the probe.
+func crashing(probe *TestProbe) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		ctx.DeliverSignals(true)
+		return func(msg interface{}) MessageHandler {
+			switch msg.(type) {
+			case PostStopSignal, ChildFailed:
+				probe.Tell(ctx, msg)
+			}
+			switch msg {
+			case "crash":
+				panic("crashed")
+			case "stop":
+				return Stopped()
+			}
+			return nil
+		}
+	}
+}
+
+var _ = Describe("Termination cause", func() {
+	var system ActorSystem
+	var probe *TestProbe
+
+	BeforeEach(func() {
+		system = Start(echo)
+		probe = NewTestProbe(GinkgoT(), system)
+	})
+
+	AfterEach(func() {
+		Expect(system.Terminate(context.Background())).To(Succeed())
+	})
+
+	It("reports panics", func() {
+		ref := system.Context().Spawn(crashing(probe))
+		probe.Watch(ref)
+		ref.Tell(system.Context(), "crash")
+
+		signal := ExpectMessageType[PostStopSignal](probe, time.Second)
+		Expect(signal.Cause.Reason).To(Equal(StopFailed))
+		Expect(signal.Cause.Panic).To(Equal("crashed"))
+		Expect(string(signal.Cause.Stack)).To(ContainSubstring("termination_test.go"))
+
+		terminated := ExpectMessageType[Terminated](probe, time.Second)
+		Expect(terminated.Ref).To(Equal(ref))
+		Expect(terminated.Cause).To(Equal(signal.Cause))
+	})
+
+	It("signals failed children to the parent", func() {
+		children := make(chan ActorRef, 2)
+		system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			children <- ctx.Spawn(crashing(probe))
+			children <- ctx.Spawn(crashing(probe))
+			return crashing(probe)(ctx)
+		})
+		stopped, crashed := <-children, <-children
+
+		stopped.Tell(system.Context(), "stop")
+		Expect(ExpectMessageType[PostStopSignal](probe, time.Second).Cause.Reason).To(Equal(StopNormal))
+		probe.ExpectNoMessage(10 * time.Millisecond)
+
+		crashed.Tell(system.Context(), "crash")
+		Expect(ExpectMessageType[PostStopSignal](probe, time.Second).Cause.Reason).To(Equal(StopFailed))
+		failed := ExpectMessageType[ChildFailed](probe, time.Second)
+		Expect(failed.Ref).To(Equal(crashed))
+		Expect(failed.Cause.Panic).To(Equal("crashed"))
+	})
+
+	It("reports stopped parents and system shutdown", func() {
+		children := make(chan ActorRef, 1)
+		parent := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			children <- ctx.Spawn(crashing(probe))
+			return crashing(probe)(ctx)
+		})
+		child := <-children
+		probe.Watch(parent)
+		probe.Watch(child)
+		parent.Tell(system.Context(), "stop")
+		Expect(ExpectMessageType[PostStopSignal](probe, time.Second).Cause.Reason).To(Equal(StopParentStopped))
+		Expect(ExpectMessageType[Terminated](probe, time.Second).Cause.Reason).To(Equal(StopParentStopped))
+		Expect(ExpectMessageType[PostStopSignal](probe, time.Second).Cause.Reason).To(Equal(StopNormal))
+		Expect(ExpectMessageType[Terminated](probe, time.Second).Cause.Reason).To(Equal(StopNormal))
+
+		probe.Watch(system.Root())
+		Expect(system.Terminate(context.Background())).To(Succeed())
+		Expect(ExpectMessageType[Terminated](probe, time.Second).Cause.Reason).To(Equal(StopSystemShutdown))
+	})
+
+	It("reports supervisor stops", func() {
+		ref := system.Context().Spawn(Supervise(crashing(probe), SupervisorStrategy{
+			Decide: func(err interface{}) SupervisorDecision {
+				return SupervisorStop
+			},
+		}))
+		probe.Watch(ref)
+		ref.Tell(system.Context(), "crash")
+		signal := ExpectMessageType[PostStopSignal](probe, time.Second)
+		Expect(signal.Cause.Reason).To(Equal(StopSupervisor))
+		Expect(signal.Cause.Panic).To(Equal("crashed"))
+		Expect(signal.Cause.Stack).NotTo(BeEmpty())
+		Expect(ExpectMessageType[Terminated](probe, time.Second).Cause).To(Equal(signal.Cause))
+	})
+})
@@ -0,0 +1,347 @@
+package tractor
+
+import (
+	"sync"
+	"time"
+)
+
+// SpawnedEffect is recorded when the actor spawns a child. The child isn't started, messages to it are collected
+// by the inbox.
+type SpawnedEffect struct {
+	Setup SetupHandler
+	Inbox *TestInbox
+}
+
+type WatchedEffect struct {
+	Ref ActorRef
+	Msg interface{}
+}
+
+type UnwatchedEffect struct {
+	Ref ActorRef
+}
+
+// ToldEffect is recorded when the actor tells a message to a TestInbox.
+type ToldEffect struct {
+	To  *TestInbox
+	Msg interface{}
+}
+
+// ScheduledEffect is recorded when the actor schedules a message to itself. Running Msg simulates the timer.
+type ScheduledEffect struct {
+	Delay time.Duration
+	Msg   interface{}
+}
+
+type UnhandledEffect struct {
+	Msg interface{}
+}
+
+type StoppedEffect struct{}
+
+// StoppedChildEffect is recorded when the actor stops a child.
+type StoppedChildEffect struct {
+	Child ActorRef
+}
+
+// TestInbox is a reference that collects told messages.
+type TestInbox struct {
+	mu       sync.Mutex
+	messages []interface{}
+}
+
+func NewTestInbox() *TestInbox {
+	return &TestInbox{}
+}
+
+func (inbox *TestInbox) Tell(ctx ActorContext, msg interface{}) {
+	inbox.mu.Lock()
+	inbox.messages = append(inbox.messages, msg)
+	inbox.mu.Unlock()
+	if kit, ok := ctx.(*testKitContext); ok {
+		kit.record(ToldEffect{To: inbox, Msg: msg})
+	}
+}
+
+// Receive removes and returns the oldest message. It returns nil if there are no messages.
+func (inbox *TestInbox) Receive() interface{} {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	if len(inbox.messages) == 0 {
+		return nil
+	}
+	msg := inbox.messages[0]
+	inbox.messages = inbox.messages[1:]
+	return msg
+}
+
+// Messages removes and returns all messages.
+func (inbox *TestInbox) Messages() []interface{} {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	messages := inbox.messages
+	inbox.messages = nil
+	return messages
+}
+
+func (inbox *TestInbox) HasMessages() bool {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	return len(inbox.messages) > 0
+}
+
+// BehaviorTestKit runs an actor synchronously on the calling goroutine with a fake context and records its effects.
+// Panics aren't recovered.
+type BehaviorTestKit struct {
+	ctx *testKitContext
+}
+
+// NewBehaviorTestKit runs the setup handler.
+func NewBehaviorTestKit(setup SetupHandler) *BehaviorTestKit {
+	ctx := &testKitContext{
+		self:   NewTestInbox(),
+		parent: NewTestInbox(),
+	}
+	ctx.events = newEventStream(ctx)
+	ctx.shutdown = newCoordinatedShutdown(nil)
+	kit := &BehaviorTestKit{ctx: ctx}
+	handler := setup(ctx)
+	if handler == nil {
+		handler = Stopped()
+	}
+	ctx.handler = handler
+	if isStopped(handler) || ctx.stopSelf {
+		ctx.stop()
+	} else if ctx.deliverSignals {
+		ctx.deliver(envelope{msg: PostInitSignal{}})
+	}
+	return kit
+}
+
+// Run delivers the message to the current handler followed by any messages unstashed while handling it.
+func (kit *BehaviorTestKit) Run(msg interface{}) {
+	kit.RunFrom(nil, msg)
+}
+
+func (kit *BehaviorTestKit) RunFrom(sender ActorRef, msg interface{}) {
+	kit.ctx.run(envelope{sender: sender, msg: msg})
+}
+
+// Handler returns the current message handler.
+func (kit *BehaviorTestKit) Handler() MessageHandler {
+	return kit.ctx.handler
+}
+
+func (kit *BehaviorTestKit) IsAlive() bool {
+	return !isStopped(kit.ctx.handler)
+}
+
+// Effects removes and returns the recorded effects.
+func (kit *BehaviorTestKit) Effects() []interface{} {
+	effects := kit.ctx.effects
+	kit.ctx.effects = nil
+	return effects
+}
+
+// Ref is the reference of the tested actor. Messages told to it are collected by the SelfInbox.
+func (kit *BehaviorTestKit) Ref() ActorRef {
+	return kit.ctx.self
+}
+
+func (kit *BehaviorTestKit) SelfInbox() *TestInbox {
+	return kit.ctx.self
+}
+
+func (kit *BehaviorTestKit) ParentInbox() *TestInbox {
+	return kit.ctx.parent
+}
+
+// Context returns the fake context of the tested actor.
+func (kit *BehaviorTestKit) Context() ActorContext {
+	return kit.ctx
+}
+
+type testKitContext struct {
+	self           *TestInbox
+	parent         *TestInbox
+	children       []ActorRef
+	handler        MessageHandler
+	deliverSignals bool
+	current        *envelope
+	unstashed      []envelope
+	effects        []interface{}
+	events         *eventStream
+	shutdown       *CoordinatedShutdown
+	stopSelf       bool
+}
+
+func (ctx *testKitContext) record(effect interface{}) {
+	ctx.effects = append(ctx.effects, effect)
+}
+
+func (ctx *testKitContext) run(env envelope) {
+	if isStopped(ctx.handler) {
+		return
+	}
+	ctx.deliver(env)
+	for len(ctx.unstashed) > 0 && !isStopped(ctx.handler) {
+		next := ctx.unstashed[0]
+		ctx.unstashed = ctx.unstashed[1:]
+		ctx.deliver(next)
+	}
+}
+
+func (ctx *testKitContext) deliver(env envelope) {
+	ctx.current = &env
+	next := ctx.handler(env.msg)
+	ctx.current = nil
+	switch {
+	case next == nil:
+	case isUnhandled(next):
+		if !isSignal(env.msg) {
+			ctx.record(UnhandledEffect{Msg: env.msg})
+		}
+	case isStopped(next):
+		ctx.stop()
+		return
+	default:
+		ctx.handler = next
+	}
+	if ctx.stopSelf && !isStopped(ctx.handler) {
+		ctx.stop()
+	}
+}
+
+func (ctx *testKitContext) stop() {
+	last := ctx.handler
+	ctx.handler = Stopped()
+	ctx.record(StoppedEffect{})
+	if ctx.deliverSignals && !isStopped(last) {
+		last(PreStopSignal{})
+		last(PostStopSignal{})
+	}
+}
+
+func (ctx *testKitContext) scheduleOnce(delay time.Duration, msg interface{}) Cancellable {
+	ctx.record(ScheduledEffect{Delay: delay, Msg: msg})
+	return testKitTimer{}
+}
+
+type testKitTimer struct{}
+
+func (testKitTimer) Stop() bool {
+	return true
+}
+
+func (ctx *testKitContext) Parent() ActorRef {
+	return ctx.parent
+}
+
+func (ctx *testKitContext) Self() ActorRef {
+	return ctx.self
+}
+
+func (ctx *testKitContext) Sender() ActorRef {
+	if ctx.current == nil {
+		return nil
+	}
+	return ctx.current.sender
+}
+
+func (ctx *testKitContext) Children() []ActorRef {
+	return append([]ActorRef(nil), ctx.children...)
+}
+
+func (ctx *testKitContext) Spawn(setup SetupHandler, _ ...SpawnOption) ActorRef {
+	inbox := NewTestInbox()
+	ctx.children = append(ctx.children, inbox)
+	ctx.record(SpawnedEffect{Setup: setup, Inbox: inbox})
+	return inbox
+}
+
+func (ctx *testKitContext) Stop(ref ActorRef) {
+	if ref == ctx.Self() {
+		ctx.stopSelf = true
+		return
+	}
+	for i, child := range ctx.children {
+		if child == ref {
+			ctx.children = append(ctx.children[:i], ctx.children[i+1:]...)
+			ctx.record(StoppedChildEffect{Child: ref})
+			return
+		}
+	}
+}
+
+func (ctx *testKitContext) Watch(actor ActorRef) {
+	ctx.WatchWith(actor, Terminated{})
+}
+
+func (ctx *testKitContext) WatchWith(actor ActorRef, msg interface{}) {
+	ctx.record(WatchedEffect{Ref: actor, Msg: msg})
+}
+
+func (ctx *testKitContext) Unwatch(actor ActorRef) {
+	ctx.record(UnwatchedEffect{Ref: actor})
+}
+
+func (ctx *testKitContext) DeliverSignals(value bool) {
+	ctx.deliverSignals = value
+}
+
+func (ctx *testKitContext) signalsEnabled() bool {
+	return ctx.deliverSignals
+}
+
+// Ask tells the message with a sender that puts the reply into the returned channel.
+func (ctx *testKitContext) Ask(ref ActorRef, msg interface{}) chan interface{} {
+	ch := make(chan interface{}, 1)
+	forwardFrom(ctx, testKitReply{ch: ch}, ref, msg)
+	return ch
+}
+
+type testKitReply struct {
+	ch chan interface{}
+}
+
+func (r testKitReply) Tell(_ ActorContext, msg interface{}) {
+	select {
+	case r.ch <- msg:
+	default:
+	}
+}
+
+func (ctx *testKitContext) NewStash(size int, options ...StashOption) StashBuffer {
+	s := &testKitStash{ctx: ctx}
+	s.stash = newStash(size, options, func(e envelope) {
+		ctx.events.publish(DeadLetter{Message: e.msg, Sender: e.sender, Recipient: ctx.self})
+	})
+	return s
+}
+
+func (ctx *testKitContext) EventStream() EventStream {
+	return ctx.events
+}
+
+// CoordinatedShutdown returns a shutdown that is only run explicitly and doesn't stop any actors.
+func (ctx *testKitContext) CoordinatedShutdown() *CoordinatedShutdown {
+	return ctx.shutdown
+}
+
+type testKitStash struct {
+	stash
+	ctx *testKitContext
+}
+
+func (s *testKitStash) Stash(msg interface{}) {
+	s.push(stashedEnvelope(s.ctx, msg))
+}
+
+func (s *testKitStash) UnstashAll(handler MessageHandler) MessageHandler {
+	return s.Unstash(handler, len(s.buffer))
+}
+
+func (s *testKitStash) Unstash(handler MessageHandler, count int) MessageHandler {
+	s.ctx.unstashed = append(s.ctx.unstashed, s.take(count)...)
+	return handler
+}
@@ -0,0 +1,152 @@
+package tractor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type greet struct {
+	name    string
+	replyTo ActorRef
+}
+
+func greeter(ctx ActorContext) MessageHandler {
+	ctx.DeliverSignals(true)
+	worker := ctx.Spawn(Countdown(1))
+	ctx.Watch(worker)
+	return func(msg interface{}) MessageHandler {
+		switch m := msg.(type) {
+		case greet:
+			m.replyTo.Tell(ctx, "hello "+m.name)
+		case Terminated:
+			return Stopped()
+		case PostInitSignal:
+			ctx.Parent().Tell(ctx, "started")
+		case PostStopSignal:
+			ctx.Parent().Tell(ctx, "stopped")
+		default:
+			return Unhandled()
+		}
+		return nil
+	}
+}
+
+// echoRef replies with the received message.
+type echoRef struct{}
+
+func (echoRef) Tell(ctx ActorContext, msg interface{}) {
+	ctx.Self().Tell(ctx, msg)
+}
+
+var _ = Describe("BehaviorTestKit", func() {
+	It("records effects", func() {
+		kit := NewBehaviorTestKit(greeter)
+		effects := kit.Effects()
+		Expect(effects).To(HaveLen(3))
+		child := effects[0].(SpawnedEffect).Inbox
+		Expect(effects[1]).To(Equal(WatchedEffect{Ref: child, Msg: Terminated{}}))
+		Expect(effects[2]).To(Equal(ToldEffect{To: kit.ParentInbox(), Msg: "started"}))
+		Expect(kit.Context().Children()).To(Equal([]ActorRef{child}))
+
+		inbox := NewTestInbox()
+		kit.Run(greet{name: "bob", replyTo: inbox})
+		Expect(inbox.Receive()).To(Equal("hello bob"))
+		Expect(inbox.HasMessages()).To(BeFalse())
+
+		kit.Run(42)
+		Expect(kit.Effects()).To(Equal([]interface{}{
+			ToldEffect{To: inbox, Msg: "hello bob"},
+			UnhandledEffect{Msg: 42},
+		}))
+		Expect(kit.IsAlive()).To(BeTrue())
+
+		kit.Run(Terminated{})
+		Expect(kit.IsAlive()).To(BeFalse())
+		Expect(kit.Effects()).To(Equal([]interface{}{
+			StoppedEffect{},
+			ToldEffect{To: kit.ParentInbox(), Msg: "stopped"},
+		}))
+		Expect(kit.ParentInbox().Messages()).To(Equal([]interface{}{"started", "stopped"}))
+	})
+
+	It("records stopped children", func() {
+		kit := NewBehaviorTestKit(func(ctx ActorContext) MessageHandler {
+			child := ctx.Spawn(echo)
+			return func(msg interface{}) MessageHandler {
+				if msg == "child" {
+					ctx.Stop(child)
+				} else {
+					ctx.Stop(ctx.Self())
+				}
+				return nil
+			}
+		})
+		child := kit.Effects()[0].(SpawnedEffect).Inbox
+		kit.Run("child")
+		Expect(kit.Effects()).To(Equal([]interface{}{StoppedChildEffect{Child: child}}))
+		Expect(kit.Context().Children()).To(BeEmpty())
+		Expect(kit.IsAlive()).To(BeTrue())
+
+		kit.Run("self")
+		Expect(kit.Effects()).To(Equal([]interface{}{StoppedEffect{}}))
+		Expect(kit.IsAlive()).To(BeFalse())
+	})
+
+	It("records scheduled timers", func() {
+		kit := NewBehaviorTestKit(WithTimers(func(timers TimerScheduler) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				timers.StartSingleTimer("key", "timeout", time.Minute)
+				return func(msg interface{}) MessageHandler {
+					ctx.Self().Tell(ctx, msg)
+					return nil
+				}
+			}
+		}))
+		effects := kit.Effects()
+		Expect(effects).To(HaveLen(1))
+		scheduled := effects[0].(ScheduledEffect)
+		Expect(scheduled.Delay).To(Equal(time.Minute))
+
+		kit.Run(scheduled.Msg)
+		Expect(kit.SelfInbox().Messages()).To(Equal([]interface{}{"timeout"}))
+		kit.Run(scheduled.Msg)
+		Expect(kit.SelfInbox().HasMessages()).To(BeFalse())
+	})
+
+	It("delivers unstashed messages", func() {
+		kit := NewBehaviorTestKit(WithStash(10, func(stash StashBuffer) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				return func(msg interface{}) MessageHandler {
+					if msg != "open" {
+						stash.Stash(msg)
+						return nil
+					}
+					return stash.UnstashAll(func(msg interface{}) MessageHandler {
+						ctx.Sender().Tell(ctx, msg)
+						return nil
+					})
+				}
+			}
+		}))
+		inbox := NewTestInbox()
+		kit.RunFrom(inbox, "a")
+		kit.RunFrom(inbox, "b")
+		Expect(inbox.HasMessages()).To(BeFalse())
+		kit.Run("open")
+		Expect(inbox.Messages()).To(Equal([]interface{}{"a", "b"}))
+	})
+
+	It("answers asks", func() {
+		var reply chan interface{}
+		kit := NewBehaviorTestKit(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				reply = ctx.Ask(echoRef{}, msg)
+				return nil
+			}
+		})
+		kit.Run("ping")
+		Expect(<-reply).To(Equal("ping"))
+	})
+})
@@ -0,0 +1,100 @@
+package tractor
+
+import "time"
+
+// TimerScheduler sends messages to the actor itself after a delay. Timers are identified by keys: starting a timer
+// cancels the previous timer with the same key, and a cancelled timer never delivers its message.
+type TimerScheduler interface {
+	StartSingleTimer(key interface{}, msg interface{}, delay time.Duration)
+	StartPeriodicTimer(key interface{}, msg interface{}, interval time.Duration)
+	IsTimerActive(key interface{}) bool
+	Cancel(key interface{})
+	CancelAll()
+}
+
+type timerMessage struct {
+	key        interface{}
+	generation int
+}
+
+type actorTimer struct {
+	generation int
+	msg        interface{}
+	interval   time.Duration
+	timer      Cancellable
+}
+
+type timerScheduler struct {
+	ctx        ActorContext
+	timers     map[interface{}]*actorTimer
+	generation int
+}
+
+// WithTimers provides timers to the actor. All timers are cancelled when the actor stops.
+func WithTimers(factory func(timers TimerScheduler) SetupHandler) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		s := &timerScheduler{ctx: ctx, timers: map[interface{}]*actorTimer{}}
+		handler := factory(s)(ctx)
+		if handler == nil || isStopped(handler) {
+			s.CancelAll()
+			return handler
+		}
+		return Intercept(handler, func(msg interface{}, target MessageHandler) MessageHandler {
+			switch m := msg.(type) {
+			case timerMessage:
+				t, ok := s.timers[m.key]
+				if !ok || t.generation != m.generation {
+					return nil
+				}
+				if t.interval > 0 {
+					t.timer = scheduleOnce(ctx, t.interval, m)
+				} else {
+					delete(s.timers, m.key)
+				}
+				msg = t.msg
+			case PostStopSignal:
+				s.CancelAll()
+			}
+			next := target(msg)
+			if isStopped(next) {
+				s.CancelAll()
+			}
+			return next
+		})
+	}
+}
+
+func (s *timerScheduler) start(key interface{}, msg interface{}, delay time.Duration, interval time.Duration) {
+	s.Cancel(key)
+	s.generation++
+	t := &actorTimer{generation: s.generation, msg: msg, interval: interval}
+	t.timer = scheduleOnce(s.ctx, delay, timerMessage{key: key, generation: t.generation})
+	s.timers[key] = t
+}
+
+func (s *timerScheduler) StartSingleTimer(key interface{}, msg interface{}, delay time.Duration) {
+	s.start(key, msg, delay, 0)
+}
+
+// StartPeriodicTimer delivers the message every interval. The next interval starts when the message is delivered.
+func (s *timerScheduler) StartPeriodicTimer(key interface{}, msg interface{}, interval time.Duration) {
+	s.start(key, msg, interval, interval)
+}
+
+func (s *timerScheduler) IsTimerActive(key interface{}) bool {
+	_, ok := s.timers[key]
+	return ok
+}
+
+func (s *timerScheduler) Cancel(key interface{}) {
+	if t, ok := s.timers[key]; ok {
+		t.timer.Stop()
+		delete(s.timers, key)
+	}
+}
+
+func (s *timerScheduler) CancelAll() {
+	for key := range s.timers {
+		s.Cancel(key)
+	}
+}
@@ -0,0 +1,63 @@
+package tractor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timers", func() {
+	It("delivers single timers", func() {
+		var received []interface{}
+		runActor(WithTimers(func(timers TimerScheduler) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				timers.StartSingleTimer("key", "tick", 10*time.Millisecond)
+				Expect(timers.IsTimerActive("key")).To(BeTrue())
+				return func(msg interface{}) MessageHandler {
+					received = append(received, msg)
+					Expect(timers.IsTimerActive("key")).To(BeFalse())
+					return Stopped()
+				}
+			}
+		}))
+		Expect(received).To(Equal([]interface{}{"tick"}))
+	})
+
+	It("replaces timers with the same key", func() {
+		var received []interface{}
+		runActor(WithTimers(func(timers TimerScheduler) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				timers.StartSingleTimer("key", "first", time.Millisecond)
+				timers.StartSingleTimer("key", "second", 20*time.Millisecond)
+				time.Sleep(10 * time.Millisecond)
+				return func(msg interface{}) MessageHandler {
+					received = append(received, msg)
+					return Stopped()
+				}
+			}
+		}))
+		Expect(received).To(Equal([]interface{}{"second"}))
+	})
+
+	It("delivers periodic timers until cancelled", func() {
+		var received []interface{}
+		runActor(WithTimers(func(timers TimerScheduler) SetupHandler {
+			return func(ctx ActorContext) MessageHandler {
+				timers.StartPeriodicTimer("key", "tick", 5*time.Millisecond)
+				return func(msg interface{}) MessageHandler {
+					received = append(received, msg)
+					if len(received) == 3 {
+						timers.Cancel("key")
+						timers.StartSingleTimer("done", "done", 20*time.Millisecond)
+					}
+					if msg == "done" {
+						return Stopped()
+					}
+					return nil
+				}
+			}
+		}))
+		Expect(received).To(Equal([]interface{}{"tick", "tick", "tick", "done"}))
+	})
+})
@@ -0,0 +1,154 @@
+package tractor
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type watchCommand struct {
+	ref ActorRef
+	msg interface{}
+}
+
+type unwatchCommand struct {
+	ref ActorRef
+}
+
+var _ = Describe("Watch", func() {
+	var system ActorSystem
+	var probe *TestProbe
+	var watcher ActorRef
+
+	// watched counts its listeners on request, stops on "stop" and panics on "fail".
+	watched := func(ctx ActorContext) MessageHandler {
+		return func(msg interface{}) MessageHandler {
+			switch msg {
+			case "stop":
+				return Stopped()
+			case "fail":
+				panic("failed")
+			case "listeners":
+				ctx.Sender().Tell(ctx, len(ctx.(*localActorContext).listeners))
+			}
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		system = Start(echo)
+		probe = NewTestProbe(GinkgoT(), system)
+		watcher = system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				switch msg := msg.(type) {
+				case watchCommand:
+					if msg.msg == nil {
+						ctx.Watch(msg.ref)
+					} else {
+						ctx.WatchWith(msg.ref, msg.msg)
+					}
+				case unwatchCommand:
+					ctx.Unwatch(msg.ref)
+				case string:
+					if msg == "stop" {
+						return Stopped()
+					}
+					probe.Tell(ctx, msg)
+				default:
+					probe.Tell(ctx, msg)
+				}
+				return nil
+			}
+		})
+	})
+
+	AfterEach(func() {
+		Expect(system.Terminate(context.Background())).To(Succeed())
+	})
+
+	It("doesn't deliver Terminated after Unwatch", func() {
+		ref := system.Context().Spawn(watched)
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "terminated"})
+		watcher.Tell(system.Context(), unwatchCommand{ref: ref})
+		Eventually(func() interface{} {
+			return <-system.Context().Ask(ref, "listeners")
+		}).Should(Equal(0))
+		ref.Tell(system.Context(), "stop")
+		probe.ExpectNoMessage(20 * time.Millisecond)
+	})
+
+	It("delivers a single message for repeated watches", func() {
+		ref := system.Context().Spawn(watched)
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "first"})
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "second"})
+		Eventually(func() interface{} {
+			return <-system.Context().Ask(ref, "listeners")
+		}).Should(Equal(1))
+		ref.Tell(system.Context(), "stop")
+		probe.ExpectMessage("second", time.Second)
+		probe.ExpectNoMessage(20 * time.Millisecond)
+	})
+
+	It("delivers Terminated for dead actors", func() {
+		ref := system.Context().Spawn(watched)
+		probe.Watch(ref)
+		ref.Tell(system.Context(), "stop")
+		probe.ExpectTerminated(ref, time.Second)
+
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "terminated"})
+		probe.ExpectMessage("terminated", time.Second)
+		probe.Watch(ref)
+		probe.ExpectTerminated(ref, time.Second)
+	})
+
+	It("fills in Terminated for dead actors", func() {
+		ref := system.Context().Spawn(watched)
+		probe.Watch(ref)
+		ref.Tell(system.Context(), "fail")
+		probe.ExpectTerminated(ref, time.Second)
+
+		watcher.Tell(system.Context(), watchCommand{ref: ref})
+		terminated := probe.ExpectTerminated(ref, time.Second)
+		Expect(terminated.Cause.Reason).To(Equal(StopFailed))
+		Expect(terminated.Cause.Panic).To(Equal("failed"))
+		probe.Watch(ref)
+		Expect(probe.ExpectTerminated(ref, time.Second).Cause.Reason).To(Equal(StopFailed))
+	})
+
+	It("removes listeners of terminated watchers", func() {
+		ref := system.Context().Spawn(watched)
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "terminated"})
+		Eventually(func() interface{} {
+			return <-system.Context().Ask(ref, "listeners")
+		}).Should(Equal(1))
+		probe.Watch(watcher)
+		watcher.Tell(system.Context(), "stop")
+		probe.ExpectTerminated(watcher, time.Second)
+		Eventually(func() interface{} {
+			return <-system.Context().Ask(ref, "listeners")
+		}).Should(Equal(0))
+	})
+
+	It("delivers Terminated after the last messages of the actor", func() {
+		ref := system.Context().Spawn(func(ctx ActorContext) MessageHandler {
+			return func(msg interface{}) MessageHandler {
+				for i := 0; i < 100; i++ {
+					watcher.Tell(ctx, i)
+				}
+				return Stopped()
+			}
+		})
+		watcher.Tell(system.Context(), watchCommand{ref: ref, msg: "terminated"})
+		Eventually(func() bool {
+			return atomic.LoadInt32(&ref.(*localActorRef).context.mailbox.pendingCount) == 0
+		}).Should(BeTrue())
+		ref.Tell(system.Context(), "go")
+		for i := 0; i < 100; i++ {
+			probe.ExpectMessage(i, time.Second)
+		}
+		probe.ExpectMessage("terminated", time.Second)
+	})
+})
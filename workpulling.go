@@ -0,0 +1,150 @@
+package tractor
+
+import "sort"
+
+type WorkPullingSettings struct {
+	// Workers is the number of Worker children spawned by the controller.
+	// Additional workers can join at any time by sending RequestWork.
+	Workers int
+	Worker  SetupHandler
+}
+
+// RequestWork signals that the sender can process Count more jobs. The first request registers
+// the sender as a worker.
+type RequestWork struct {
+	Count int
+}
+
+// Job is sent to a worker that requested work. The worker is expected to reply with JobDone to ConfirmTo.
+type Job struct {
+	ID        uint64
+	Message   interface{}
+	ConfirmTo ActorRef
+}
+
+type JobDone struct {
+	ID uint64
+}
+
+type GetWorkPullingStats struct{}
+
+type WorkPullingStats struct {
+	Backlog     int
+	InFlight    int
+	Workers     int
+	Completed   int
+	Redelivered int
+}
+
+type workerTerminated struct {
+	ref ActorRef
+}
+
+type workerState struct {
+	ref      ActorRef
+	demand   int
+	inFlight map[uint64]Job
+}
+
+// WorkPullingProducerController distributes messages sent to it as jobs among workers. Jobs are handed to
+// workers only when they request work; jobs of terminated workers are redelivered to other workers.
+func WorkPullingProducerController(settings WorkPullingSettings) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		var workers []*workerState
+		var backlog []Job
+		var nextID uint64
+		next := 0
+		stats := WorkPullingStats{}
+
+		find := func(ref ActorRef) *workerState {
+			for _, w := range workers {
+				if w.ref == ref {
+					return w
+				}
+			}
+			return nil
+		}
+
+		dispatch := func() {
+			for len(backlog) > 0 {
+				var worker *workerState
+				// round-robin among the workers that have demand
+				for i := 0; i < len(workers); i++ {
+					w := workers[(next+i)%len(workers)]
+					if w.demand > 0 {
+						worker = w
+						next = (next + i + 1) % len(workers)
+						break
+					}
+				}
+				if worker == nil {
+					return
+				}
+				job := backlog[0]
+				backlog = backlog[1:]
+				worker.demand--
+				worker.inFlight[job.ID] = job
+				worker.ref.Tell(ctx, job)
+			}
+		}
+
+		for i := 0; i < settings.Workers; i++ {
+			ref := ctx.Spawn(settings.Worker)
+			ctx.WatchWith(ref, workerTerminated{ref: ref})
+			workers = append(workers, &workerState{ref: ref, inFlight: map[uint64]Job{}})
+		}
+
+		return func(msg interface{}) MessageHandler {
+			switch m := msg.(type) {
+			case RequestWork:
+				w := find(ctx.Sender())
+				if w == nil {
+					w = &workerState{ref: ctx.Sender(), inFlight: map[uint64]Job{}}
+					workers = append(workers, w)
+					ctx.WatchWith(w.ref, workerTerminated{ref: w.ref})
+				}
+				w.demand += m.Count
+				dispatch()
+			case JobDone:
+				if w := find(ctx.Sender()); w != nil {
+					if _, ok := w.inFlight[m.ID]; ok {
+						delete(w.inFlight, m.ID)
+						stats.Completed++
+					}
+				}
+			case workerTerminated:
+				w := find(m.ref)
+				if w == nil {
+					return nil
+				}
+				for i, worker := range workers {
+					if worker == w {
+						workers = append(workers[:i], workers[i+1:]...)
+						break
+					}
+				}
+				var redelivered []Job
+				for _, job := range w.inFlight {
+					redelivered = append(redelivered, job)
+				}
+				sort.Slice(redelivered, func(i, j int) bool { return redelivered[i].ID < redelivered[j].ID })
+				stats.Redelivered += len(redelivered)
+				backlog = append(redelivered, backlog...)
+				dispatch()
+			case GetWorkPullingStats:
+				result := stats
+				result.Backlog = len(backlog)
+				result.Workers = len(workers)
+				for _, w := range workers {
+					result.InFlight += len(w.inFlight)
+				}
+				ctx.Sender().Tell(ctx, result)
+			default:
+				nextID++
+				backlog = append(backlog, Job{ID: nextID, Message: msg, ConfirmTo: ctx.Self()})
+				dispatch()
+			}
+			return nil
+		}
+	}
+}
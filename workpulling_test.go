@@ -0,0 +1,120 @@
+package tractor
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type jobRecorder struct {
+	mu   sync.Mutex
+	jobs []interface{}
+}
+
+func (r *jobRecorder) add(msg interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, msg)
+}
+
+func (r *jobRecorder) get() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]interface{}(nil), r.jobs...)
+}
+
+func pullingWorker(recorder *jobRecorder) SetupHandler {
+	return func(ctx ActorContext) MessageHandler {
+		ctx.Parent().Tell(ctx, RequestWork{Count: 1})
+		return func(msg interface{}) MessageHandler {
+			if job, ok := msg.(Job); ok {
+				recorder.add(job.Message)
+				job.ConfirmTo.Tell(ctx, JobDone{ID: job.ID})
+				ctx.Parent().Tell(ctx, RequestWork{Count: 1})
+			}
+			return nil
+		}
+	}
+}
+
+var _ = Describe("Work pulling", func() {
+	It("distributes jobs among spawned workers", func() {
+		recorder := &jobRecorder{}
+		root := func(ctx ActorContext) MessageHandler {
+			controller := ctx.Spawn(WorkPullingProducerController(WorkPullingSettings{Workers: 3, Worker: pullingWorker(recorder)}))
+			for i := 0; i < 30; i++ {
+				controller.Tell(ctx, i)
+			}
+			Eventually(func() int {
+				return (<-ctx.Ask(controller, GetWorkPullingStats{})).(WorkPullingStats).Completed
+			}).Should(Equal(30))
+			stats := (<-ctx.Ask(controller, GetWorkPullingStats{})).(WorkPullingStats)
+			Expect(stats).To(Equal(WorkPullingStats{Workers: 3, Completed: 30}))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(recorder.get()).To(ConsistOf(numbers(30)...))
+	})
+
+	It("keeps jobs in the backlog until workers request them", func() {
+		root := func(ctx ActorContext) MessageHandler {
+			controller := ctx.Spawn(WorkPullingProducerController(WorkPullingSettings{}))
+			controller.Tell(ctx, "a")
+			controller.Tell(ctx, "b")
+			Expect(<-ctx.Ask(controller, GetWorkPullingStats{})).To(Equal(WorkPullingStats{Backlog: 2}))
+
+			job := <-ctx.Ask(controller, RequestWork{Count: 1})
+			Expect(job.(Job).Message).To(Equal("a"))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+	})
+
+	It("redelivers jobs of terminated workers", func() {
+		recorder := &jobRecorder{}
+		root := func(ctx ActorContext) MessageHandler {
+			controller := ctx.Spawn(WorkPullingProducerController(WorkPullingSettings{}))
+			controller.Tell(ctx, "a")
+			controller.Tell(ctx, "b")
+
+			// a worker that takes two jobs and dies without confirming them
+			ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				controller.Tell(ctx, RequestWork{Count: 2})
+				received := 0
+				return func(msg interface{}) MessageHandler {
+					received++
+					if received == 2 {
+						return Stopped()
+					}
+					return nil
+				}
+			})
+			Eventually(func() WorkPullingStats {
+				return (<-ctx.Ask(controller, GetWorkPullingStats{})).(WorkPullingStats)
+			}).Should(Equal(WorkPullingStats{Backlog: 2, Redelivered: 2}))
+
+			ctx.Spawn(func(ctx ActorContext) MessageHandler {
+				controller.Tell(ctx, RequestWork{Count: 2})
+				return func(msg interface{}) MessageHandler {
+					job := msg.(Job)
+					recorder.add(job.Message)
+					job.ConfirmTo.Tell(ctx, JobDone{ID: job.ID})
+					return nil
+				}
+			})
+			Eventually(func() int {
+				return (<-ctx.Ask(controller, GetWorkPullingStats{})).(WorkPullingStats).Completed
+			}).Should(Equal(2))
+			return Stopped()
+		}
+
+		system := Start(root)
+		system.Wait()
+		Expect(recorder.get()).To(Equal([]interface{}{"a", "b"}))
+	})
+})